@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
@@ -11,8 +12,11 @@ import (
 	"event-pipeline/internal/config"
 	"event-pipeline/internal/consumer"
 	"event-pipeline/internal/database"
+	"event-pipeline/internal/dedup"
 	"event-pipeline/internal/dlq"
 	"event-pipeline/internal/logger"
+	"event-pipeline/internal/parking"
+	"event-pipeline/internal/producer"
 )
 
 func main() {
@@ -39,17 +43,85 @@ func main() {
 	defer dlqClient.Close()
 
 	// Initialize consumer
-	kafkaConsumer, err := consumer.New(&cfg.Kafka, db, dlqClient)
+	kafkaConsumer, err := consumer.NewFromConfig(cfg, db, dlqClient)
 	if err != nil {
 		logger.Log.Fatalf("Failed to create consumer: %v", err)
 	}
 	defer kafkaConsumer.Stop()
 
+	// Initialize retry topic consumers, one per configured tier
+	consumers := []*consumer.Consumer{kafkaConsumer}
+	if len(cfg.Kafka.RetryTiers) > 0 && cfg.Transport != config.TransportKafka {
+		logger.Log.Warnf("Retry topics require the Kafka transport, failures on %s go straight to the DLQ", cfg.Transport)
+	} else if len(cfg.Kafka.RetryTiers) > 0 {
+		retryProducer, err := producer.New(&cfg.Kafka)
+		if err != nil {
+			logger.Log.Fatalf("Failed to create retry producer: %v", err)
+		}
+		defer retryProducer.Close()
+
+		router := consumer.NewRetryRouter(&cfg.Kafka, retryProducer)
+		kafkaConsumer.SetRetryRouter(router)
+
+		for i := range router.Tiers() {
+			tierConsumer, err := consumer.NewRetryTierConsumer(&cfg.Kafka, router, i, db, dlqClient)
+			if err != nil {
+				logger.Log.Fatalf("Failed to create retry tier consumer: %v", err)
+			}
+			tierConsumer.CopyHandlers(kafkaConsumer)
+			defer tierConsumer.Stop()
+			consumers = append(consumers, tierConsumer)
+		}
+	}
+
+	// Wrap every handler; Recover is inside Tracing, Logging and Metrics so
+	// panics are traced, logged and measured like any other failure
+	middleware := []consumer.Middleware{
+		consumer.Tracing(),
+		consumer.Logging(),
+		consumer.Metrics(),
+		consumer.Recover(),
+	}
+	if cfg.Kafka.HandlerTimeout > 0 {
+		middleware = append(middleware, consumer.Timeout(cfg.Kafka.HandlerTimeout))
+	}
+
+	// Skip redelivered duplicates before they reach the database
+	if cfg.Kafka.DedupEnabled() {
+		filter, err := dedup.New(&cfg.Redis)
+		if err != nil {
+			logger.Log.Fatalf("Failed to create duplicate filter: %v", err)
+		}
+		defer filter.Close()
+
+		middleware = append(middleware, consumer.Dedup(filter, consumer.DedupPolicyFromConfig(&cfg.Kafka)))
+	}
+
+	for _, c := range consumers {
+		c.Use(middleware...)
+	}
+
+	// Initialize parking for events that arrive before their parent
+	if cfg.Kafka.ParkingTimeout > 0 {
+		parkingStore, err := parking.New(&cfg.Redis)
+		if err != nil {
+			logger.Log.Fatalf("Failed to create parking store: %v", err)
+		}
+		defer parkingStore.Close()
+
+		for _, c := range consumers {
+			c.SetParking(parkingStore, cfg.Kafka.ParkingTimeout)
+		}
+	}
+
 	// Initialize API server
 	apiServer := api.New(&cfg.API, db)
+	apiServer.AttachConsumer(kafkaConsumer)
 
-	// Start consumer in goroutine
-	go kafkaConsumer.Start()
+	// Start consumers in goroutines
+	for _, c := range consumers {
+		go c.Start()
+	}
 
 	// Start API server in goroutine
 	go func() {
@@ -65,6 +137,21 @@ func main() {
 
 	logger.Log.Info("Shutting down gracefully...")
 
+	// Finish in-flight events and commit their offsets before closing Kafka
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), cfg.Kafka.DrainTimeout)
+	var wg sync.WaitGroup
+	for _, c := range consumers {
+		wg.Add(1)
+		go func(c *consumer.Consumer) {
+			defer wg.Done()
+			if report := c.Drain(drainCtx); !report.Completed {
+				logger.Log.Warnf("Drain timed out, %d messages left for redelivery", report.Unprocessed)
+			}
+		}(c)
+	}
+	wg.Wait()
+	drainCancel()
+
 	// Graceful shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -113,8 +113,8 @@ func main() {
 			Timestamp: time.Now(),
 		},
 		SKU:            "LAPTOP-PRO-15",
-		Quantity:       -1,
-		AdjustmentType: "order_fulfilled",
+		Quantity:       1,
+		AdjustmentType: models.AdjustmentSubtract,
 		Reason:         fmt.Sprintf("Order %s shipped", demo.OrderID),
 		AdjustedAt:     time.Now(),
 	}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"event-pipeline/internal/config"
+	"event-pipeline/internal/ingest"
+	"event-pipeline/internal/logger"
+	"event-pipeline/internal/producer"
+)
+
+func main() {
+	logger.Log.Info("Starting Event Ingestion Server...")
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		logger.Log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Initialize producer
+	prod, err := producer.NewFromConfig(cfg)
+	if err != nil {
+		logger.Log.Fatalf("Failed to create producer: %v", err)
+	}
+	defer prod.Close()
+
+	// Start ingestion server in goroutine
+	server := ingest.New(&cfg.Ingest, prod)
+	go func() {
+		if err := server.Start(); err != nil && err != http.ErrServerClosed {
+			logger.Log.Fatalf("Ingestion server error: %v", err)
+		}
+	}()
+
+	// Wait for interrupt signal
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	<-sigChan
+
+	logger.Log.Info("Shutting down gracefully...")
+
+	// Let requests waiting for delivery finish before the producer closes
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	if err := server.Stop(ctx); err != nil {
+		logger.Log.Errorf("Error stopping ingestion server: %v", err)
+	}
+
+	logger.Log.Info("Shutdown complete")
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"event-pipeline/internal/config"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// timeoutMs bounds each broker request
+const timeoutMs = 10000
+
+func main() {
+	toTimestamp := flag.String("to-timestamp", "", "reset to the first offset at or after this RFC3339 time")
+	toOffset := flag.Int64("to-offset", -1, "reset every selected partition to this offset")
+	toEarliest := flag.Bool("to-earliest", false, "reset to the earliest available offset")
+	toLatest := flag.Bool("to-latest", false, "reset to the end of the topic")
+	partitionsFlag := flag.String("partitions", "", "comma separated partitions to reset (default: all)")
+	dryRun := flag.Bool("dry-run", false, "print the planned offsets without changing them")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: offset-reset (-to-timestamp T | -to-offset N | -to-earliest | -to-latest) [-partitions 0,1] [-dry-run]\n\n")
+		fmt.Fprintf(os.Stderr, "Resets the offsets of KAFKA_CONSUMER_GROUP on KAFKA_TOPIC. The consumer group must be stopped.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	targets := 0
+	for _, set := range []bool{*toTimestamp != "", *toOffset >= 0, *toEarliest, *toLatest} {
+		if set {
+			targets++
+		}
+	}
+	if targets != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var at time.Time
+	if *toTimestamp != "" {
+		var err error
+		if at, err = time.Parse(time.RFC3339, *toTimestamp); err != nil {
+			log.Fatalf("Invalid -to-timestamp: %v", err)
+		}
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	topic, group := cfg.Kafka.Topic, cfg.Kafka.ConsumerGroup
+
+	// The consumer never subscribes, so it does not join the group
+	c, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers":  cfg.Kafka.Brokers,
+		"group.id":           group,
+		"enable.auto.commit": false,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create consumer: %v", err)
+	}
+	defer c.Close()
+
+	admin, err := kafka.NewAdminClientFromConsumer(c)
+	if err != nil {
+		log.Fatalf("Failed to create admin client: %v", err)
+	}
+	defer admin.Close()
+
+	partitions, err := selectPartitions(c, topic, *partitionsFlag)
+	if err != nil {
+		log.Fatalf("Failed to list partitions: %v", err)
+	}
+
+	current, err := committedOffsets(admin, group, topic, partitions)
+	if err != nil {
+		log.Fatalf("Failed to fetch committed offsets: %v", err)
+	}
+
+	planned := make(map[int32]int64, len(partitions))
+	for _, p := range partitions {
+		low, high, err := c.QueryWatermarkOffsets(topic, p, timeoutMs)
+		if err != nil {
+			log.Fatalf("Failed to query offsets of partition %d: %v", p, err)
+		}
+
+		switch {
+		case *toEarliest:
+			planned[p] = low
+		case *toLatest:
+			planned[p] = high
+		case *toOffset >= 0:
+			// Clamp to the available range, like kafka-consumer-groups does
+			planned[p] = min(max(*toOffset, low), high)
+		default:
+			offset, err := offsetForTime(c, topic, p, at)
+			if err != nil {
+				log.Fatalf("Failed to look up offset of partition %d: %v", p, err)
+			}
+			if offset < 0 {
+				offset = high
+			}
+			planned[p] = offset
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "GROUP\tTOPIC\tPARTITION\tCURRENT\tNEW\n")
+	for _, p := range partitions {
+		cur := "-"
+		if offset, ok := current[p]; ok {
+			cur = strconv.FormatInt(offset, 10)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%d\n", group, topic, p, cur, planned[p])
+	}
+	w.Flush()
+
+	if *dryRun {
+		fmt.Println("\nDry run: no offsets were changed")
+		return
+	}
+
+	if err := ensureInactive(admin, group); err != nil {
+		log.Fatal(err)
+	}
+	if err := resetOffsets(admin, group, topic, partitions, planned); err != nil {
+		log.Fatalf("Failed to reset offsets: %v", err)
+	}
+	fmt.Printf("\nReset offsets of %d partitions\n", len(partitions))
+}
+
+// selectPartitions returns the partitions of topic, restricted to the comma
+// separated list in only when it is not empty
+func selectPartitions(c *kafka.Consumer, topic, only string) ([]int32, error) {
+	md, err := c.GetMetadata(&topic, false, timeoutMs)
+	if err != nil {
+		return nil, err
+	}
+	tm, ok := md.Topics[topic]
+	if !ok || tm.Error.Code() != kafka.ErrNoError {
+		return nil, fmt.Errorf("topic %s not found", topic)
+	}
+
+	exists := make(map[int32]bool, len(tm.Partitions))
+	var all []int32
+	for _, p := range tm.Partitions {
+		exists[p.ID] = true
+		all = append(all, p.ID)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+
+	if only == "" {
+		return all, nil
+	}
+
+	var selected []int32
+	for _, s := range strings.Split(only, ",") {
+		p, err := strconv.ParseInt(strings.TrimSpace(s), 10, 32)
+		if err != nil || !exists[int32(p)] {
+			return nil, fmt.Errorf("partition %q does not exist in %s", s, topic)
+		}
+		selected = append(selected, int32(p))
+	}
+	return selected, nil
+}
+
+// committedOffsets returns the group's committed offset per partition
+func committedOffsets(admin *kafka.AdminClient, group, topic string, partitions []int32) (map[int32]int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutMs*time.Millisecond)
+	defer cancel()
+
+	res, err := admin.ListConsumerGroupOffsets(ctx, []kafka.ConsumerGroupTopicPartitions{{
+		Group:      group,
+		Partitions: topicPartitions(topic, partitions, nil),
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := make(map[int32]int64)
+	for _, g := range res.ConsumerGroupsTopicPartitions {
+		for _, tp := range g.Partitions {
+			if tp.Error == nil && tp.Offset >= 0 {
+				offsets[tp.Partition] = int64(tp.Offset)
+			}
+		}
+	}
+	return offsets, nil
+}
+
+// offsetForTime returns the first offset of a partition at or after t, or a
+// negative offset if no message is that recent
+func offsetForTime(c *kafka.Consumer, topic string, partition int32, t time.Time) (int64, error) {
+	offsets, err := c.OffsetsForTimes([]kafka.TopicPartition{{
+		Topic:     &topic,
+		Partition: partition,
+		Offset:    kafka.Offset(t.UnixMilli()),
+	}}, timeoutMs)
+	if err != nil {
+		return 0, err
+	}
+	if len(offsets) != 1 {
+		return 0, fmt.Errorf("unexpected response %v", offsets)
+	}
+	if offsets[0].Error != nil {
+		return 0, offsets[0].Error
+	}
+	return int64(offsets[0].Offset), nil
+}
+
+// ensureInactive fails if the group has active members, whose commits would
+// overwrite the reset
+func ensureInactive(admin *kafka.AdminClient, group string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutMs*time.Millisecond)
+	defer cancel()
+
+	res, err := admin.DescribeConsumerGroups(ctx, []string{group})
+	if err != nil {
+		return fmt.Errorf("failed to describe consumer group: %w", err)
+	}
+	for _, desc := range res.ConsumerGroupDescriptions {
+		if desc.State != kafka.ConsumerGroupStateEmpty && desc.State != kafka.ConsumerGroupStateDead {
+			return fmt.Errorf("consumer group %s is %s with %d members; stop the consumers first", group, desc.State, len(desc.Members))
+		}
+	}
+	return nil
+}
+
+// resetOffsets commits the planned offsets for the group
+func resetOffsets(admin *kafka.AdminClient, group, topic string, partitions []int32, planned map[int32]int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeoutMs*time.Millisecond)
+	defer cancel()
+
+	res, err := admin.AlterConsumerGroupOffsets(ctx, []kafka.ConsumerGroupTopicPartitions{{
+		Group:      group,
+		Partitions: topicPartitions(topic, partitions, planned),
+	}})
+	if err != nil {
+		return err
+	}
+	for _, g := range res.ConsumerGroupsTopicPartitions {
+		for _, tp := range g.Partitions {
+			if tp.Error != nil {
+				return fmt.Errorf("partition %d: %w", tp.Partition, tp.Error)
+			}
+		}
+	}
+	return nil
+}
+
+// topicPartitions builds the partition list for admin requests, with offsets
+// taken from offsets when given
+func topicPartitions(topic string, partitions []int32, offsets map[int32]int64) []kafka.TopicPartition {
+	tps := make([]kafka.TopicPartition, 0, len(partitions))
+	for _, p := range partitions {
+		tp := kafka.TopicPartition{Topic: &topic, Partition: p}
+		if offsets != nil {
+			tp.Offset = kafka.Offset(offsets[p])
+		}
+		tps = append(tps, tp)
+	}
+	return tps
+}
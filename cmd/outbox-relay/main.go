@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"event-pipeline/internal/config"
+	"event-pipeline/internal/database"
+	"event-pipeline/internal/logger"
+	"event-pipeline/internal/metrics"
+	"event-pipeline/internal/producer"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	logger.Log.Info("Starting Outbox Relay...")
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		logger.Log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Initialize database
+	db, err := database.New(&cfg.MSSQL)
+	if err != nil {
+		logger.Log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	// Initialize producer
+	prod, err := producer.NewFromConfig(cfg)
+	if err != nil {
+		logger.Log.Fatalf("Failed to create producer: %v", err)
+	}
+	defer prod.Close()
+
+	// Serve metrics
+	metricsServer := &http.Server{Addr: ":" + cfg.Metrics.Port, Handler: promhttp.Handler()}
+	go func() {
+		if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Log.Fatalf("Metrics server error: %v", err)
+		}
+	}()
+
+	// Stop between batches on interrupt; a batch in progress is finished so
+	// its published messages are marked sent
+	ctx, cancel := context.WithCancel(context.Background())
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		logger.Log.Info("Shutting down gracefully...")
+		cancel()
+	}()
+
+	publish := func(m database.OutboxMessage) error {
+		if err := prod.PublishRaw(cfg.Kafka.Topic, []byte(m.Key), m.Payload, nil); err != nil {
+			metrics.OutboxRelayed.WithLabelValues(string(m.EventType), "failed").Inc()
+			return err
+		}
+
+		metrics.OutboxRelayed.WithLabelValues(string(m.EventType), "sent").Inc()
+		metrics.OutboxLag.Observe(time.Since(m.CreatedAt).Seconds())
+		logger.WithEventID(m.EventID).WithFields(logrus.Fields{
+			"eventType": m.EventType,
+			"outboxId":  m.ID,
+		}).Debug("Outbox message published")
+		return nil
+	}
+
+	logger.Log.Infof("Relaying outbox to topic %s", cfg.Kafka.Topic)
+	for ctx.Err() == nil {
+		sent, err := db.RelayOutbox(context.Background(), cfg.Outbox.BatchSize, cfg.Outbox.MaxAttempts, publish)
+		if err != nil {
+			logger.Log.Errorf("Outbox relay error: %v", err)
+		}
+
+		// A full batch means more messages are likely waiting
+		if err == nil && sent == cfg.Outbox.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(cfg.Outbox.PollInterval):
+		}
+	}
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+		logger.Log.Errorf("Error stopping metrics server: %v", err)
+	}
+
+	logger.Log.Info("Shutdown complete")
+}
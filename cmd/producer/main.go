@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"strings"
@@ -24,7 +25,7 @@ func main() {
 	}
 
 	// Initialize producer
-	prod, err := producer.New(&cfg.Kafka)
+	prod, err := producer.NewFromConfig(cfg)
 	if err != nil {
 		logger.Log.Fatalf("Failed to create producer: %v", err)
 	}
@@ -244,9 +245,10 @@ func generateSampleEvents(prod *producer.Producer) {
 	}
 	fmt.Printf("✓ Settled 3 payments\n")
 
-	// Adjust inventory
-	for i := 0; i < 5; i++ {
-		event := models.InventoryAdjusted{
+	// Adjust inventory in one batch, without waiting on each delivery
+	adjustments := make([]models.Publishable, 5)
+	for i := range adjustments {
+		adjustments[i] = &models.InventoryAdjusted{
 			BaseEvent: models.BaseEvent{
 				EventID:   uuid.New().String(),
 				Timestamp: time.Now(),
@@ -257,9 +259,11 @@ func generateSampleEvents(prod *producer.Producer) {
 			Reason:         "initial_stock",
 			AdjustedAt:     time.Now(),
 		}
-		
-		if err := prod.PublishInventoryAdjusted(event); err != nil {
-			logger.Log.Errorf("Failed to publish InventoryAdjusted: %v", err)
+	}
+
+	for _, result := range prod.PublishBatch(context.Background(), adjustments) {
+		if result.Err != nil {
+			logger.Log.Errorf("Failed to publish InventoryAdjusted: %v", result.Err)
 		}
 	}
 	fmt.Printf("✓ Adjusted inventory for 5 items\n")
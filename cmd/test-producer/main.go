@@ -23,7 +23,7 @@ func main() {
 	}
 
 	// Initialize producer
-	prod, err := producer.New(&cfg.Kafka)
+	prod, err := producer.NewFromConfig(cfg)
 	if err != nil {
 		logger.Log.Fatalf("Failed to create producer: %v", err)
 	}
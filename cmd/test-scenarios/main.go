@@ -154,7 +154,8 @@ func testEdgeCases(p *kafka.Producer, topic string) {
 	}
 	sendEvent(p, topic, order, "Edge: Large amount")
 
-	// Test 5: Negative inventory adjustment
+	// Test 5: Negative inventory adjustment (should go to DLQ, the direction
+	// belongs in adjustmentType)
 	inv := models.InventoryAdjusted{
 		BaseEvent: models.BaseEvent{
 			EventID:   uuid.New().String(),
@@ -162,8 +163,8 @@ func testEdgeCases(p *kafka.Producer, topic string) {
 			Timestamp: time.Now(),
 		},
 		SKU:            "EDGE-TEST-001",
-		Quantity:       -50, // Negative adjustment
-		AdjustmentType: "returned",
+		Quantity:       -50, // Would turn the add into a subtract
+		AdjustmentType: models.AdjustmentAdd,
 		Reason:         "customer return",
 		AdjustedAt:     time.Now(),
 	}
@@ -237,12 +238,14 @@ func testConcurrentBurst(p *kafka.Producer, topic string) {
 func testLargePayload(p *kafka.Producer, topic string) {
 	// Create order with many items
 	items := make([]models.OrderItem, 50)
+	var total float64
 	for i := 0; i < 50; i++ {
 		items[i] = models.OrderItem{
 			SKU:      fmt.Sprintf("ITEM-%d", i),
 			Quantity: i + 1,
 			Price:    float64(i) * 10.50,
 		}
+		total += items[i].Price * float64(items[i].Quantity)
 	}
 
 	order := models.OrderPlaced{
@@ -253,7 +256,7 @@ func testLargePayload(p *kafka.Producer, topic string) {
 		},
 		OrderID:     uuid.New().String(),
 		UserID:      uuid.New().String(),
-		TotalAmount: 12345.67,
+		TotalAmount: total, // Must match the items to pass validation
 		Currency:    "USD",
 		Items:       items,
 		PlacedAt:    time.Now(),
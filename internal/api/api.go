@@ -2,11 +2,15 @@ package api
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"event-pipeline/internal/config"
+	"event-pipeline/internal/consumer"
 	"event-pipeline/internal/database"
 	"event-pipeline/internal/logger"
 	"strconv"
@@ -15,12 +19,29 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// ConsumerController exposes the running consumer to the API
+type ConsumerController interface {
+	AssignedPartitions() []consumer.PartitionAssignment
+	State() consumer.ConsumerState
+	Pause(ctx context.Context) error
+	Resume(ctx context.Context) error
+	PausePartition(ctx context.Context, topic string, partition int32) error
+	ResumePartition(ctx context.Context, topic string, partition int32) error
+	SeekPartition(ctx context.Context, topic string, partition int32, offset int64) error
+	SeekPartitionToTime(ctx context.Context, topic string, partition int32, t time.Time) (int64, error)
+}
+
+// adminRequestTimeout bounds how long an admin request waits for the
+// consumer to apply it, within the server's write timeout
+const adminRequestTimeout = 10 * time.Second
+
 // Server represents the API server
 type Server struct {
-	router *mux.Router
-	db     *database.DB
-	cfg    *config.APIConfig
-	server *http.Server
+	router   *mux.Router
+	db       *database.DB
+	cfg      *config.APIConfig
+	server   *http.Server
+	consumer ConsumerController
 }
 
 // New creates a new API server
@@ -45,10 +66,28 @@ func (s *Server) setupRoutes() {
 	s.router.HandleFunc("/users/{id}", s.getUser).Methods("GET")
 	s.router.HandleFunc("/orders/{id}", s.getOrder).Methods("GET")
 
+	// Consumer routes
+	s.router.HandleFunc("/consumer/partitions", s.getPartitions).Methods("GET")
+	s.router.HandleFunc("/consumer/state", s.getConsumerState).Methods("GET")
+
+	// Consumer admin routes
+	admin := s.router.PathPrefix("/consumer").Methods("POST").Subrouter()
+	admin.Use(s.requireAdmin)
+	admin.HandleFunc("/pause", s.pauseConsumer)
+	admin.HandleFunc("/resume", s.resumeConsumer)
+	admin.HandleFunc("/partitions/{partition}/pause", s.pausePartition)
+	admin.HandleFunc("/partitions/{partition}/resume", s.resumePartition)
+	admin.HandleFunc("/partitions/{partition}/seek", s.seekPartition)
+
 	// Metrics endpoint
 	s.router.Handle("/metrics", promhttp.Handler())
 }
 
+// AttachConsumer makes the consumer's state available through the API
+func (s *Server) AttachConsumer(c ConsumerController) {
+	s.consumer = c
+}
+
 // Start starts the API server
 func (s *Server) Start() error {
 	s.server = &http.Server{
@@ -69,14 +108,30 @@ func (s *Server) Stop(ctx context.Context) error {
 	return s.server.Shutdown(ctx)
 }
 
-// healthCheck handles health check requests
+// healthCheck handles health check requests. It reports unhealthy while
+// the attached consumer is reconnecting or stopped.
 func (s *Server) healthCheck(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
+	body := map[string]string{
 		"status": "healthy",
 		"time":   time.Now().Format(time.RFC3339),
-	})
+	}
+	code := http.StatusOK
+
+	if s.consumer != nil {
+		state := s.consumer.State()
+		body["consumer"] = state.State
+		if state.State == consumer.StateReconnecting || state.State == consumer.StateStopped {
+			body["status"] = "unhealthy"
+			code = http.StatusServiceUnavailable
+		}
+		if state.LastError != "" {
+			body["error"] = state.LastError
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
 }
 
 // getUser handles GET /users/{id}
@@ -143,3 +198,174 @@ func (s *Server) getRecentUsers(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(users)
 }
+
+// getPartitions handles GET /consumer/partitions
+func (s *Server) getPartitions(w http.ResponseWriter, r *http.Request) {
+	if s.consumer == nil {
+		http.Error(w, "consumer not attached", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(s.consumer.AssignedPartitions())
+}
+
+// getConsumerState handles GET /consumer/state
+func (s *Server) getConsumerState(w http.ResponseWriter, r *http.Request) {
+	if s.consumer == nil {
+		http.Error(w, "consumer not attached", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(s.consumer.State())
+}
+
+// pauseConsumer handles POST /consumer/pause
+func (s *Server) pauseConsumer(w http.ResponseWriter, r *http.Request) {
+	if s.consumer == nil {
+		http.Error(w, "consumer not attached", http.StatusServiceUnavailable)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), adminRequestTimeout)
+	defer cancel()
+	s.writeConsumerResult(w, s.consumer.Pause(ctx))
+}
+
+// resumeConsumer handles POST /consumer/resume
+func (s *Server) resumeConsumer(w http.ResponseWriter, r *http.Request) {
+	if s.consumer == nil {
+		http.Error(w, "consumer not attached", http.StatusServiceUnavailable)
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), adminRequestTimeout)
+	defer cancel()
+	s.writeConsumerResult(w, s.consumer.Resume(ctx))
+}
+
+// pausePartition handles POST /consumer/partitions/{partition}/pause?topic=T
+func (s *Server) pausePartition(w http.ResponseWriter, r *http.Request) {
+	if s.consumer == nil {
+		http.Error(w, "consumer not attached", http.StatusServiceUnavailable)
+		return
+	}
+	partition, ok := partitionParam(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), adminRequestTimeout)
+	defer cancel()
+	s.writeConsumerResult(w, s.consumer.PausePartition(ctx, r.URL.Query().Get("topic"), partition))
+}
+
+// resumePartition handles POST /consumer/partitions/{partition}/resume?topic=T
+func (s *Server) resumePartition(w http.ResponseWriter, r *http.Request) {
+	if s.consumer == nil {
+		http.Error(w, "consumer not attached", http.StatusServiceUnavailable)
+		return
+	}
+	partition, ok := partitionParam(w, r)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), adminRequestTimeout)
+	defer cancel()
+	s.writeConsumerResult(w, s.consumer.ResumePartition(ctx, r.URL.Query().Get("topic"), partition))
+}
+
+// seekRequest is the body of a seek request; exactly one field must be set
+type seekRequest struct {
+	Offset    *int64     `json:"offset"`
+	Timestamp *time.Time `json:"timestamp"`
+}
+
+// seekPartition handles POST /consumer/partitions/{partition}/seek?topic=T
+func (s *Server) seekPartition(w http.ResponseWriter, r *http.Request) {
+	if s.consumer == nil {
+		http.Error(w, "consumer not attached", http.StatusServiceUnavailable)
+		return
+	}
+	partition, ok := partitionParam(w, r)
+	if !ok {
+		return
+	}
+
+	var req seekRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if (req.Offset == nil) == (req.Timestamp == nil) {
+		http.Error(w, "exactly one of offset or timestamp is required", http.StatusBadRequest)
+		return
+	}
+	if req.Offset != nil && *req.Offset < 0 {
+		http.Error(w, "offset must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), adminRequestTimeout)
+	defer cancel()
+
+	topic := r.URL.Query().Get("topic")
+	var err error
+	if req.Offset != nil {
+		err = s.consumer.SeekPartition(ctx, topic, partition, *req.Offset)
+	} else {
+		_, err = s.consumer.SeekPartitionToTime(ctx, topic, partition, *req.Timestamp)
+	}
+	s.writeConsumerResult(w, err)
+}
+
+// requireAdmin lets through only requests bearing the admin token. Without
+// a configured token every request is refused, so the admin routes are off.
+func (s *Server) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.cfg.AdminToken == "" {
+			http.Error(w, "consumer admin routes are disabled", http.StatusForbidden)
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.AdminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// partitionParam parses the {partition} route variable, writing a 400 if invalid
+func partitionParam(w http.ResponseWriter, r *http.Request) (int32, bool) {
+	partition, err := strconv.ParseInt(mux.Vars(r)["partition"], 10, 32)
+	if err != nil || partition < 0 {
+		http.Error(w, "invalid partition", http.StatusBadRequest)
+		return 0, false
+	}
+	return int32(partition), true
+}
+
+// writeConsumerResult writes the consumer state after an admin operation, or
+// the operation's error
+func (s *Server) writeConsumerResult(w http.ResponseWriter, err error) {
+	if err != nil {
+		logger.Log.Errorf("Consumer admin operation failed: %v", err)
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, consumer.ErrNotRunning), errors.Is(err, consumer.ErrPollLoopBusy):
+			status = http.StatusServiceUnavailable
+		case errors.Is(err, consumer.ErrPartitionNotAssigned):
+			status = http.StatusNotFound
+		case errors.Is(err, consumer.ErrConsumerPaused):
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(s.consumer.State())
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"event-pipeline/internal/config"
+	"event-pipeline/internal/consumer"
+)
+
+// stubConsumer is a ConsumerController whose Pause waits for its context
+// while busy, as when the poll loop is held up
+type stubConsumer struct{ busy, paused bool }
+
+func (c *stubConsumer) AssignedPartitions() []consumer.PartitionAssignment { return nil }
+func (c *stubConsumer) State() consumer.ConsumerState {
+	return consumer.ConsumerState{State: consumer.StateRunning}
+}
+func (c *stubConsumer) Resume(ctx context.Context) error { return nil }
+func (c *stubConsumer) PausePartition(ctx context.Context, topic string, partition int32) error {
+	return nil
+}
+func (c *stubConsumer) ResumePartition(ctx context.Context, topic string, partition int32) error {
+	return nil
+}
+func (c *stubConsumer) SeekPartition(ctx context.Context, topic string, partition int32, offset int64) error {
+	return nil
+}
+func (c *stubConsumer) SeekPartitionToTime(ctx context.Context, topic string, partition int32, t time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (c *stubConsumer) Pause(ctx context.Context) error {
+	if _, ok := ctx.Deadline(); !ok {
+		return fmt.Errorf("expected a deadline on the admin request")
+	}
+	if c.busy {
+		<-ctx.Done()
+		return fmt.Errorf("%w: %v", consumer.ErrPollLoopBusy, ctx.Err())
+	}
+	c.paused = true
+	return nil
+}
+
+func TestAdminRoutesRequireToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		want   int
+	}{
+		{"disabled without a token", "", "Bearer ", http.StatusForbidden},
+		{"missing header", "secret", "", http.StatusUnauthorized},
+		{"wrong token", "secret", "Bearer other", http.StatusUnauthorized},
+		{"not a bearer token", "secret", "secret", http.StatusUnauthorized},
+		{"valid token", "secret", "Bearer secret", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		s := New(&config.APIConfig{AdminToken: tt.token}, nil)
+		c := &stubConsumer{}
+		s.AttachConsumer(c)
+
+		req := httptest.NewRequest(http.MethodPost, "/consumer/pause", nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, req)
+
+		if rec.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.name, tt.want, rec.Code, rec.Body)
+		}
+		if c.paused != (tt.want == http.StatusOK) {
+			t.Errorf("%s: expected paused %v, got %v", tt.name, tt.want == http.StatusOK, c.paused)
+		}
+	}
+
+	// Reading the consumer's state needs no token
+	s := New(&config.APIConfig{}, nil)
+	s.AttachConsumer(&stubConsumer{})
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/consumer/state", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected GET /consumer/state to be open, got %d", rec.Code)
+	}
+}
+
+func TestAdminRequestTimesOutWhenPollLoopIsBusy(t *testing.T) {
+	s := New(&config.APIConfig{AdminToken: "secret"}, nil)
+	s.AttachConsumer(&stubConsumer{busy: true})
+
+	// The request's own context ending stands in for the admin timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodPost, "/consumer/pause", nil).WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 when the consumer cannot apply the change in time, got %d", rec.Code)
+	}
+}
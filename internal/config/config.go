@@ -4,16 +4,40 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
+
+	"event-pipeline/internal/models"
 
 	"github.com/joho/godotenv"
 )
 
+// Transports that carry events from producers to consumers
+const (
+	TransportKafka = "kafka"
+	TransportRedis = "redis"
+	TransportFile  = "file"
+)
+
+// What the consumer does with an event while the duplicate filter is unavailable
+const (
+	DedupFallbackProcess = "process"
+	DedupFallbackRetry   = "retry"
+)
+
 // Config holds all configuration for the application
 type Config struct {
+	// Transport selects the message broker; the Kafka topic and consumer
+	// group settings apply to every transport
+	Transport string
+
 	Kafka   KafkaConfig
 	MSSQL   MSSQLConfig
 	Redis   RedisConfig
+	File    FileConfig
+	Outbox  OutboxConfig
 	API     APIConfig
+	Ingest  IngestConfig
 	Metrics MetricsConfig
 }
 
@@ -22,6 +46,64 @@ type KafkaConfig struct {
 	Brokers       string
 	Topic         string
 	ConsumerGroup string
+
+	// Messages a producer may have awaiting delivery reports; publishing
+	// waits for a free slot beyond that
+	ProducerMaxPending int
+
+	// Producer reliability and throughput tuning; nil values and an empty
+	// compression codec keep the client defaults, so that a zero can still
+	// be passed to the client. Idempotence keeps retries from duplicating or
+	// reordering messages and allows at most 5 requests in flight.
+	ProducerIdempotence     bool
+	ProducerCompression     string
+	ProducerLinger          *time.Duration
+	ProducerBatchSize       *int // bytes
+	ProducerBatchMessages   *int
+	ProducerMaxInFlight     *int
+	ProducerDeliveryTimeout *time.Duration // 0 means no limit
+	ProducerRetries         *int
+
+	// Partition assignment strategy, e.g. "cooperative-sticky"; empty uses the client default
+	AssignmentStrategy string
+
+	// Consumer retry policy applied before a message is dead-lettered
+	RetryMaxAttempts int
+	RetryBaseBackoff time.Duration
+	RetryMaxBackoff  time.Duration
+	RetryJitter      float64
+
+	// How long a partition stays paused after a transient failure exhausts its retries
+	TransientPause time.Duration
+
+	// Upper bound on each handler attempt; zero leaves attempts unbounded
+	HandlerTimeout time.Duration
+
+	// Number of workers processing messages concurrently, partitioned by message key
+	Workers int
+
+	// Micro-batching of database writes per worker; a batch size of 1 disables it
+	BatchSize   int
+	BatchWindow time.Duration
+
+	// Upper bound on waiting for in-flight messages during shutdown
+	DrainTimeout time.Duration
+
+	// Delays of the retry topics a failed message passes through, in order,
+	// before it is dead-lettered; empty sends failures straight to the DLQ
+	RetryTiers []time.Duration
+
+	// How long an event waiting for a missing parent row stays parked before
+	// it is dead-lettered; zero disables parking
+	ParkingTimeout time.Duration
+
+	// Window in which an event ID seen before is skipped by the duplicate
+	// filter; DedupTTLs overrides it per event type and zero disables it.
+	// DedupFallback decides whether events are processed unfiltered or
+	// retried later while Redis is unavailable.
+	DedupTTL      time.Duration
+	DedupTTLs     map[string]time.Duration
+	DedupFallback string
 }
 
 // MSSQLConfig holds MS SQL configuration
@@ -40,11 +122,57 @@ type RedisConfig struct {
 	Password string
 	DB       int
 	DLQKey   string
+
+	// Key prefix under which events waiting for a parent row are parked
+	ParkingKey string
+
+	// Key prefix under which the duplicate filter records event IDs
+	DedupKey string
+
+	// Redis Streams transport: each topic is split into StreamShards streams
+	// by key, trimmed to roughly StreamMaxLen entries (0 keeps everything).
+	// Entries left pending by a consumer for StreamClaimIdle are claimed by
+	// another member of the group.
+	StreamShards    int
+	StreamMaxLen    int64
+	StreamClaimIdle time.Duration
+	StreamConsumer  string
+}
+
+// FileConfig holds configuration of the local file transport
+type FileConfig struct {
+	// Directory holding one NDJSON file per topic; the consumer reads every
+	// file in it and records its position under Dir/.checkpoints
+	Dir string
+}
+
+// OutboxConfig holds configuration of the outbox relay
+type OutboxConfig struct {
+	// How often the relay looks for unsent messages when the outbox is
+	// empty, and how many it claims at a time
+	PollInterval time.Duration
+	BatchSize    int
+
+	// Failed publishes after which a message is parked and no longer holds
+	// up later messages with its key
+	MaxAttempts int
 }
 
 // APIConfig holds API server configuration
 type APIConfig struct {
 	Port string
+
+	// Bearer token required by the /consumer routes that change the
+	// consumer; those routes are disabled when it is empty
+	AdminToken string
+}
+
+// IngestConfig holds configuration of the HTTP ingestion server
+type IngestConfig struct {
+	Port string
+
+	// Largest request body accepted, in bytes
+	MaxBodyBytes int64
 }
 
 // MetricsConfig holds metrics server configuration
@@ -72,11 +200,239 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("invalid MSSQL_PORT: %w", err)
 	}
 
+	retryMaxAttempts, err := strconv.Atoi(getEnv("CONSUMER_RETRY_MAX_ATTEMPTS", "3"))
+	if err != nil || retryMaxAttempts < 1 {
+		return nil, fmt.Errorf("invalid CONSUMER_RETRY_MAX_ATTEMPTS: must be a positive integer")
+	}
+
+	retryBaseBackoff, err := time.ParseDuration(getEnv("CONSUMER_RETRY_BASE_BACKOFF", "200ms"))
+	if err != nil || retryBaseBackoff < 0 {
+		return nil, fmt.Errorf("invalid CONSUMER_RETRY_BASE_BACKOFF: must be a non-negative duration")
+	}
+
+	retryMaxBackoff, err := time.ParseDuration(getEnv("CONSUMER_RETRY_MAX_BACKOFF", "5s"))
+	if err != nil || retryMaxBackoff < retryBaseBackoff {
+		return nil, fmt.Errorf("invalid CONSUMER_RETRY_MAX_BACKOFF: must be a duration of at least CONSUMER_RETRY_BASE_BACKOFF")
+	}
+
+	retryJitter, err := strconv.ParseFloat(getEnv("CONSUMER_RETRY_JITTER", "0.2"), 64)
+	if err != nil || retryJitter < 0 || retryJitter > 1 {
+		return nil, fmt.Errorf("invalid CONSUMER_RETRY_JITTER: must be between 0 and 1")
+	}
+
+	transientPause, err := time.ParseDuration(getEnv("CONSUMER_TRANSIENT_PAUSE", "30s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CONSUMER_TRANSIENT_PAUSE: %w", err)
+	}
+
+	handlerTimeout, err := time.ParseDuration(getEnv("CONSUMER_HANDLER_TIMEOUT", "5s"))
+	if err != nil || handlerTimeout < 0 {
+		return nil, fmt.Errorf("invalid CONSUMER_HANDLER_TIMEOUT: must be a non-negative duration")
+	}
+
+	workers, err := strconv.Atoi(getEnv("CONSUMER_WORKERS", "4"))
+	if err != nil || workers < 1 {
+		return nil, fmt.Errorf("invalid CONSUMER_WORKERS: must be a positive integer")
+	}
+
+	batchSize, err := strconv.Atoi(getEnv("CONSUMER_BATCH_SIZE", "1"))
+	if err != nil || batchSize < 1 {
+		return nil, fmt.Errorf("invalid CONSUMER_BATCH_SIZE: must be a positive integer")
+	}
+
+	batchWindow, err := time.ParseDuration(getEnv("CONSUMER_BATCH_WINDOW", "50ms"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CONSUMER_BATCH_WINDOW: %w", err)
+	}
+
+	drainTimeout, err := time.ParseDuration(getEnv("CONSUMER_DRAIN_TIMEOUT", "20s"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CONSUMER_DRAIN_TIMEOUT: %w", err)
+	}
+
+	parkingTimeout, err := time.ParseDuration(getEnv("CONSUMER_PARKING_TIMEOUT", "10m"))
+	if err != nil || parkingTimeout < 0 {
+		return nil, fmt.Errorf("invalid CONSUMER_PARKING_TIMEOUT: must be a non-negative duration")
+	}
+
+	dedupTTL, err := time.ParseDuration(getEnv("CONSUMER_DEDUP_TTL", "0s"))
+	if err != nil || dedupTTL < 0 {
+		return nil, fmt.Errorf("invalid CONSUMER_DEDUP_TTL: must be a non-negative duration")
+	}
+
+	dedupTTLs := make(map[string]time.Duration)
+	if ttls := getEnv("CONSUMER_DEDUP_TTLS", ""); ttls != "" {
+		for _, entry := range strings.Split(ttls, ",") {
+			eventType, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+			ttl, err := time.ParseDuration(value)
+			if !ok || eventType == "" || err != nil || ttl < 0 {
+				return nil, fmt.Errorf("invalid CONSUMER_DEDUP_TTLS: %q is not EventType=duration", entry)
+			}
+			if !models.EventType(eventType).Known() {
+				return nil, fmt.Errorf("invalid CONSUMER_DEDUP_TTLS: unknown event type %q", eventType)
+			}
+			dedupTTLs[eventType] = ttl
+		}
+	}
+
+	dedupFallback := getEnv("CONSUMER_DEDUP_FALLBACK", DedupFallbackProcess)
+	switch dedupFallback {
+	case DedupFallbackProcess, DedupFallbackRetry:
+	default:
+		return nil, fmt.Errorf("invalid CONSUMER_DEDUP_FALLBACK: must be process or retry")
+	}
+
+	producerMaxPending, err := strconv.Atoi(getEnv("PRODUCER_MAX_PENDING", "10000"))
+	if err != nil || producerMaxPending < 1 {
+		return nil, fmt.Errorf("invalid PRODUCER_MAX_PENDING: must be a positive integer")
+	}
+
+	producerIdempotence, err := strconv.ParseBool(getEnv("PRODUCER_ENABLE_IDEMPOTENCE", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PRODUCER_ENABLE_IDEMPOTENCE: must be true or false")
+	}
+
+	producerCompression := os.Getenv("PRODUCER_COMPRESSION")
+	switch producerCompression {
+	case "", "none", "gzip", "snappy", "lz4", "zstd":
+	default:
+		return nil, fmt.Errorf("invalid PRODUCER_COMPRESSION: must be none, gzip, snappy, lz4 or zstd")
+	}
+
+	producerLinger, err := optionalDuration("PRODUCER_LINGER")
+	if err != nil || producerLinger != nil && (*producerLinger < 0 || *producerLinger > 900*time.Second) {
+		return nil, fmt.Errorf("invalid PRODUCER_LINGER: must be a duration between 0 and 900s")
+	}
+
+	producerBatchSize, err := optionalInt("PRODUCER_BATCH_SIZE")
+	if err != nil || producerBatchSize != nil && *producerBatchSize < 1 {
+		return nil, fmt.Errorf("invalid PRODUCER_BATCH_SIZE: must be a positive number of bytes")
+	}
+
+	producerBatchMessages, err := optionalInt("PRODUCER_BATCH_MESSAGES")
+	if err != nil || producerBatchMessages != nil && *producerBatchMessages < 1 {
+		return nil, fmt.Errorf("invalid PRODUCER_BATCH_MESSAGES: must be a positive integer")
+	}
+
+	producerMaxInFlight, err := optionalInt("PRODUCER_MAX_IN_FLIGHT")
+	if err != nil || producerMaxInFlight != nil && *producerMaxInFlight < 1 {
+		return nil, fmt.Errorf("invalid PRODUCER_MAX_IN_FLIGHT: must be a positive integer")
+	}
+	if producerIdempotence && producerMaxInFlight != nil && *producerMaxInFlight > 5 {
+		return nil, fmt.Errorf("invalid PRODUCER_MAX_IN_FLIGHT: must be at most 5 with PRODUCER_ENABLE_IDEMPOTENCE")
+	}
+
+	producerDeliveryTimeout, err := optionalDuration("PRODUCER_DELIVERY_TIMEOUT")
+	if err != nil || producerDeliveryTimeout != nil && *producerDeliveryTimeout < 0 {
+		return nil, fmt.Errorf("invalid PRODUCER_DELIVERY_TIMEOUT: must be a non-negative duration")
+	}
+	if producerDeliveryTimeout != nil && producerLinger != nil && *producerDeliveryTimeout > 0 && *producerDeliveryTimeout < *producerLinger {
+		return nil, fmt.Errorf("invalid PRODUCER_DELIVERY_TIMEOUT: must not be shorter than PRODUCER_LINGER")
+	}
+
+	producerRetries, err := optionalInt("PRODUCER_RETRIES")
+	if err != nil || producerRetries != nil && *producerRetries < 0 {
+		return nil, fmt.Errorf("invalid PRODUCER_RETRIES: must be a non-negative integer")
+	}
+
+	outboxPollInterval, err := time.ParseDuration(getEnv("OUTBOX_POLL_INTERVAL", "1s"))
+	if err != nil || outboxPollInterval <= 0 {
+		return nil, fmt.Errorf("invalid OUTBOX_POLL_INTERVAL: must be a positive duration")
+	}
+
+	outboxBatchSize, err := strconv.Atoi(getEnv("OUTBOX_BATCH_SIZE", "100"))
+	if err != nil || outboxBatchSize < 1 || outboxBatchSize > 1000 {
+		return nil, fmt.Errorf("invalid OUTBOX_BATCH_SIZE: must be between 1 and 1000")
+	}
+
+	outboxMaxAttempts, err := strconv.Atoi(getEnv("OUTBOX_MAX_ATTEMPTS", "5"))
+	if err != nil || outboxMaxAttempts < 1 {
+		return nil, fmt.Errorf("invalid OUTBOX_MAX_ATTEMPTS: must be a positive integer")
+	}
+
+	ingestMaxBodyBytes, err := strconv.ParseInt(getEnv("INGEST_MAX_BODY_BYTES", "1048576"), 10, 64)
+	if err != nil || ingestMaxBodyBytes < 1 {
+		return nil, fmt.Errorf("invalid INGEST_MAX_BODY_BYTES: must be a positive number of bytes")
+	}
+
+	transport := getEnv("EVENT_TRANSPORT", TransportKafka)
+	switch transport {
+	case TransportKafka, TransportRedis, TransportFile:
+	default:
+		return nil, fmt.Errorf("invalid EVENT_TRANSPORT: must be kafka, redis or file")
+	}
+
+	streamShards, err := strconv.Atoi(getEnv("REDIS_STREAM_SHARDS", "4"))
+	if err != nil || streamShards < 1 {
+		return nil, fmt.Errorf("invalid REDIS_STREAM_SHARDS: must be a positive integer")
+	}
+
+	streamMaxLen, err := strconv.ParseInt(getEnv("REDIS_STREAM_MAXLEN", "1000000"), 10, 64)
+	if err != nil || streamMaxLen < 0 {
+		return nil, fmt.Errorf("invalid REDIS_STREAM_MAXLEN: must be a non-negative integer")
+	}
+
+	streamClaimIdle, err := time.ParseDuration(getEnv("REDIS_STREAM_CLAIM_IDLE", "1m"))
+	if err != nil || streamClaimIdle <= 0 {
+		return nil, fmt.Errorf("invalid REDIS_STREAM_CLAIM_IDLE: must be a positive duration")
+	}
+
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "consumer"
+	}
+
+	switch os.Getenv("KAFKA_ASSIGNMENT_STRATEGY") {
+	case "", "range", "roundrobin", "range,roundrobin", "cooperative-sticky":
+	default:
+		return nil, fmt.Errorf("invalid KAFKA_ASSIGNMENT_STRATEGY: must be range, roundrobin or cooperative-sticky")
+	}
+
+	var retryTiers []time.Duration
+	if tiers := getEnv("KAFKA_RETRY_TIERS", ""); tiers != "" {
+		for _, tier := range strings.Split(tiers, ",") {
+			delay, err := time.ParseDuration(strings.TrimSpace(tier))
+			if err != nil || delay <= 0 {
+				return nil, fmt.Errorf("invalid KAFKA_RETRY_TIERS: %q is not a positive duration", tier)
+			}
+			retryTiers = append(retryTiers, delay)
+		}
+	}
+
 	return &Config{
+		Transport: transport,
 		Kafka: KafkaConfig{
 			Brokers:       getEnv("KAFKA_BROKERS", "localhost:9092"),
 			Topic:         getEnv("KAFKA_TOPIC", "events"),
 			ConsumerGroup: getEnv("KAFKA_CONSUMER_GROUP", "event-consumer-group"),
+
+			ProducerMaxPending: producerMaxPending,
+			AssignmentStrategy: os.Getenv("KAFKA_ASSIGNMENT_STRATEGY"),
+
+			ProducerIdempotence:     producerIdempotence,
+			ProducerCompression:     producerCompression,
+			ProducerLinger:          producerLinger,
+			ProducerBatchSize:       producerBatchSize,
+			ProducerBatchMessages:   producerBatchMessages,
+			ProducerMaxInFlight:     producerMaxInFlight,
+			ProducerDeliveryTimeout: producerDeliveryTimeout,
+			ProducerRetries:         producerRetries,
+
+			RetryMaxAttempts: retryMaxAttempts,
+			RetryBaseBackoff: retryBaseBackoff,
+			RetryMaxBackoff:  retryMaxBackoff,
+			RetryJitter:      retryJitter,
+			TransientPause:   transientPause,
+			HandlerTimeout:   handlerTimeout,
+			Workers:          workers,
+			BatchSize:        batchSize,
+			BatchWindow:      batchWindow,
+			DrainTimeout:     drainTimeout,
+			RetryTiers:       retryTiers,
+			ParkingTimeout:   parkingTimeout,
+			DedupTTL:         dedupTTL,
+			DedupTTLs:        dedupTTLs,
+			DedupFallback:    dedupFallback,
 		},
 		MSSQL: MSSQLConfig{
 			Server:   getEnv("MSSQL_SERVER", "localhost"),
@@ -91,9 +447,30 @@ func Load() (*Config, error) {
 			Password: getEnv("REDIS_PASSWORD", ""),
 			DB:       redisDB,
 			DLQKey:   getEnv("REDIS_DLQ_KEY", "dlq:events"),
+
+			ParkingKey: getEnv("REDIS_PARKING_KEY", "parked:events"),
+			DedupKey:   getEnv("REDIS_DEDUP_KEY", "dedup:events"),
+
+			StreamShards:    streamShards,
+			StreamMaxLen:    streamMaxLen,
+			StreamClaimIdle: streamClaimIdle,
+			StreamConsumer:  getEnv("REDIS_STREAM_CONSUMER", hostname),
+		},
+		File: FileConfig{
+			Dir: getEnv("EVENT_FILE_DIR", "data/events"),
+		},
+		Outbox: OutboxConfig{
+			PollInterval: outboxPollInterval,
+			BatchSize:    outboxBatchSize,
+			MaxAttempts:  outboxMaxAttempts,
 		},
 		API: APIConfig{
-			Port: getEnv("API_PORT", "8080"),
+			Port:       getEnv("API_PORT", "8080"),
+			AdminToken: getEnv("API_ADMIN_TOKEN", ""),
+		},
+		Ingest: IngestConfig{
+			Port:         getEnv("INGEST_PORT", "8081"),
+			MaxBodyBytes: ingestMaxBodyBytes,
 		},
 		Metrics: MetricsConfig{
 			Port: getEnv("METRICS_PORT", "9090"),
@@ -101,6 +478,31 @@ func Load() (*Config, error) {
 	}, nil
 }
 
+// RetryTopic returns the retry topic name for a tier delay, e.g. "events.retry.5m"
+func (c *KafkaConfig) RetryTopic(delay time.Duration) string {
+	d := delay.String()
+	if strings.HasSuffix(d, "m0s") {
+		d = strings.TrimSuffix(d, "0s")
+	}
+	if strings.HasSuffix(d, "h0m") {
+		d = strings.TrimSuffix(d, "0m")
+	}
+	return fmt.Sprintf("%s.retry.%s", c.Topic, d)
+}
+
+// DedupEnabled reports whether the duplicate filter applies to any event type
+func (c *KafkaConfig) DedupEnabled() bool {
+	if c.DedupTTL > 0 {
+		return true
+	}
+	for _, ttl := range c.DedupTTLs {
+		if ttl > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 // GetConnectionString returns MS SQL connection string
 func (c *MSSQLConfig) GetConnectionString() string {
 	return fmt.Sprintf("server=%s;port=%d;user id=%s;password=%s;database=%s;encrypt=disable",
@@ -118,3 +520,29 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// optionalInt parses an integer setting, returning nil if it is not set
+func optionalInt(key string) (*int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// optionalDuration parses a duration setting, returning nil if it is not set
+func optionalDuration(key string) (*time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
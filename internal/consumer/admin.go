@@ -0,0 +1,286 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"event-pipeline/internal/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+// adminTimeout bounds broker lookups made for admin requests
+const adminTimeout = 5 * time.Second
+
+var (
+	// ErrNotRunning is returned by admin operations while the poll loop is not running
+	ErrNotRunning = errors.New("consumer is not running")
+
+	// ErrPartitionNotAssigned is returned for partitions the consumer does not own
+	ErrPartitionNotAssigned = errors.New("partition is not assigned to this consumer")
+
+	// ErrConsumerPaused is returned when resuming a partition while the whole consumer is paused
+	ErrConsumerPaused = errors.New("consumer is paused, resume it first")
+
+	// ErrPollLoopBusy is returned when an admin operation's context ends
+	// before the poll loop can run it
+	ErrPollLoopBusy = errors.New("consumer poll loop is busy, try again later")
+)
+
+// Consumer states reported by State
+const (
+	StateIdle    = "idle"
+	StateRunning = "running"
+	StatePaused  = "paused"
+	StateStopped = "stopped"
+
+	// StateReconnecting means the source failed fatally and is being reopened
+	StateReconnecting = "reconnecting"
+)
+
+// ConsumerState describes the consumer for operators
+type ConsumerState struct {
+	State      string                `json:"state"`
+	Topic      string                `json:"topic"`
+	Workers    int                   `json:"workers"`
+	BatchSize  int                   `json:"batchSize"`
+	Partitions []PartitionAssignment `json:"partitions"`
+	Reconnects int                   `json:"reconnects"`
+	LastError  string                `json:"lastError,omitempty"`
+}
+
+// State returns the consumer's current state and partition assignment
+func (c *Consumer) State() ConsumerState {
+	c.mu.RLock()
+	state := StateRunning
+	switch {
+	case c.pollDone == nil:
+		state = StateIdle
+	case c.pollCtx.Err() != nil:
+		state = StateStopped
+	case c.sourceErr != nil:
+		state = StateReconnecting
+	case c.pausedAll:
+		state = StatePaused
+	}
+	reconnects := c.reconnects
+	var lastError string
+	if c.sourceErr != nil {
+		lastError = c.sourceErr.Error()
+	}
+	c.mu.RUnlock()
+
+	return ConsumerState{
+		State:      state,
+		Topic:      c.topic,
+		Workers:    c.workers,
+		BatchSize:  c.batchSize,
+		Partitions: c.AssignedPartitions(),
+		Reconnects: reconnects,
+		LastError:  lastError,
+	}
+}
+
+// Pause stops fetching from every assigned partition, including partitions
+// assigned later, until Resume is called. Messages already fetched are
+// still processed.
+func (c *Consumer) Pause(ctx context.Context) error {
+	return c.onPollLoop(ctx, func() error {
+		partitions, err := c.source.Assignment()
+		if err != nil {
+			return fmt.Errorf("failed to get assignment: %w", err)
+		}
+		if err := c.source.Pause(partitions); err != nil {
+			return fmt.Errorf("failed to pause partitions: %w", err)
+		}
+
+		c.mu.Lock()
+		c.pausedAll = true
+		c.mu.Unlock()
+
+		logger.Log.WithField("partitions", partitionList(partitions)).Warn("Consumer paused by operator")
+		return nil
+	})
+}
+
+// Resume resumes fetching from every partition paused by an operator.
+// Partitions backing off after a transient failure stay paused until their
+// backoff ends.
+func (c *Consumer) Resume(ctx context.Context) error {
+	return c.onPollLoop(ctx, func() error {
+		partitions, err := c.source.Assignment()
+		if err != nil {
+			return fmt.Errorf("failed to get assignment: %w", err)
+		}
+
+		c.mu.Lock()
+		c.pausedAll = false
+		c.paused = make(map[partitionKey]bool)
+		resumable := make([]TopicPartition, 0, len(partitions))
+		for _, tp := range partitions {
+			if !c.backoff[keyOf(tp)] {
+				resumable = append(resumable, tp)
+			}
+		}
+		c.mu.Unlock()
+
+		if err := c.source.Resume(resumable); err != nil {
+			return fmt.Errorf("failed to resume partitions: %w", err)
+		}
+
+		logger.Log.WithField("partitions", partitionList(resumable)).Warn("Consumer resumed by operator")
+		return nil
+	})
+}
+
+// PausePartition stops fetching from a single partition until ResumePartition
+// is called
+func (c *Consumer) PausePartition(ctx context.Context, topic string, partition int32) error {
+	return c.onPollLoop(ctx, func() error {
+		tp, err := c.assignedPartition(topic, partition)
+		if err != nil {
+			return err
+		}
+		if err := c.source.Pause([]TopicPartition{tp}); err != nil {
+			return fmt.Errorf("failed to pause partition %d: %w", partition, err)
+		}
+
+		c.mu.Lock()
+		c.paused[keyOf(tp)] = true
+		c.mu.Unlock()
+
+		logger.Log.WithFields(logrus.Fields{
+			"topic":     topic,
+			"partition": partition,
+		}).Warn("Partition paused by operator")
+		return nil
+	})
+}
+
+// ResumePartition resumes a partition paused by PausePartition. It fails if
+// the whole consumer is paused.
+func (c *Consumer) ResumePartition(ctx context.Context, topic string, partition int32) error {
+	return c.onPollLoop(ctx, func() error {
+		tp, err := c.assignedPartition(topic, partition)
+		if err != nil {
+			return err
+		}
+		key := keyOf(tp)
+
+		c.mu.Lock()
+		if c.pausedAll {
+			c.mu.Unlock()
+			return ErrConsumerPaused
+		}
+		delete(c.paused, key)
+		backoff := c.backoff[key]
+		c.mu.Unlock()
+
+		if !backoff {
+			if err := c.source.Resume([]TopicPartition{tp}); err != nil {
+				return fmt.Errorf("failed to resume partition %d: %w", partition, err)
+			}
+		}
+
+		logger.Log.WithFields(logrus.Fields{
+			"topic":     topic,
+			"partition": partition,
+		}).Warn("Partition resumed by operator")
+		return nil
+	})
+}
+
+// SeekPartition moves a partition to offset. Work in flight for the
+// partition is discarded, and the new position is committed even when it is
+// behind the last committed offset.
+func (c *Consumer) SeekPartition(ctx context.Context, topic string, partition int32, offset int64) error {
+	if offset < 0 {
+		return fmt.Errorf("invalid offset %d", offset)
+	}
+
+	return c.onPollLoop(ctx, func() error {
+		tp, err := c.assignedPartition(topic, partition)
+		if err != nil {
+			return err
+		}
+
+		c.offsets.seek(keyOf(tp), offset)
+		tp.Offset = offset
+		if err := c.source.Seek(tp); err != nil {
+			return fmt.Errorf("failed to seek partition %d: %w", partition, err)
+		}
+
+		logger.Log.WithFields(logrus.Fields{
+			"topic":     topic,
+			"partition": partition,
+			"offset":    offset,
+		}).Warn("Partition repositioned by operator")
+		return nil
+	})
+}
+
+// SeekPartitionToTime moves a partition to the first message produced at or
+// after t, or to the end of the partition if there is none. It returns the
+// offset sought to.
+func (c *Consumer) SeekPartitionToTime(ctx context.Context, topic string, partition int32, t time.Time) (int64, error) {
+	if topic == "" {
+		topic = c.topic
+	}
+
+	offset, err := c.source.OffsetForTime(TopicPartition{Topic: topic, Partition: partition}, t)
+	if err != nil {
+		return 0, err
+	}
+
+	return offset, c.SeekPartition(ctx, topic, partition, offset)
+}
+
+// assignedPartition returns the partition if it is currently assigned
+func (c *Consumer) assignedPartition(topic string, partition int32) (TopicPartition, error) {
+	if topic == "" {
+		topic = c.topic
+	}
+	tp := TopicPartition{Topic: topic, Partition: partition}
+
+	c.mu.RLock()
+	_, ok := c.assigned[keyOf(tp)]
+	c.mu.RUnlock()
+
+	if !ok {
+		return tp, fmt.Errorf("%w: %s[%d]", ErrPartitionNotAssigned, topic, partition)
+	}
+	return tp, nil
+}
+
+// onPollLoop runs fn on the poll goroutine between reads, so that it never
+// races with a message being fetched, and returns its error. The poll loop
+// can be held up for a long time, for example while every worker is busy, so
+// it gives up when ctx ends before fn is picked up, without running it.
+func (c *Consumer) onPollLoop(ctx context.Context, fn func() error) error {
+	c.mu.RLock()
+	done := c.pollDone
+	c.mu.RUnlock()
+
+	if done == nil || c.pollCtx.Err() != nil {
+		return ErrNotRunning
+	}
+
+	result := make(chan error, 1)
+	select {
+	case c.control <- func() { result <- fn() }:
+		return <-result
+	case <-done:
+		return ErrNotRunning
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %v", ErrPollLoopBusy, ctx.Err())
+	}
+}
+
+// operatorPaused reports whether an operator has paused the partition
+func (c *Consumer) operatorPaused(key partitionKey) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.pausedAll || c.paused[key]
+}
@@ -0,0 +1,27 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOnPollLoopGivesUpWhenContextEnds(t *testing.T) {
+	// A running poll loop that never gets to its control channel
+	c := &Consumer{
+		pollCtx:  context.Background(),
+		pollDone: make(chan struct{}),
+		control:  make(chan func()),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := c.onPollLoop(ctx, func() error {
+		t.Error("Expected the operation not to run")
+		return nil
+	})
+	if !errors.Is(err, ErrPollLoopBusy) {
+		t.Errorf("Expected ErrPollLoopBusy, got %v", err)
+	}
+}
@@ -0,0 +1,176 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"runtime/debug"
+	"time"
+
+	"event-pipeline/internal/database"
+	"event-pipeline/internal/logger"
+	"event-pipeline/internal/metrics"
+	"event-pipeline/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// batchSlot is a message's place in a pending database batch. The message
+// is processed on its own goroutine through the full handler chain, whose
+// innermost handler adds the event to the batch and waits at the slot for
+// the batch to be written.
+type batchSlot struct {
+	handler BatchHandler
+	batch   *database.Batch
+	used    bool // set on the first call; retries write the event alone
+
+	ready   chan bool     // true once added to the batch, false to be written alone
+	written chan error    // the batch outcome, or nil to go ahead alone
+	done    chan struct{} // closed once the message is finished with
+}
+
+func newBatchSlot(handler BatchHandler, batch *database.Batch) *batchSlot {
+	return &batchSlot{
+		handler: handler,
+		batch:   batch,
+		ready:   make(chan bool),
+		written: make(chan error),
+		done:    make(chan struct{}),
+	}
+}
+
+// Handle adds the event to the batch and waits for it to be written,
+// writing the event alone if it cannot be batched or the batch fails
+func (s *batchSlot) Handle(ctx context.Context, base models.BaseEvent, data []byte) error {
+	if s.used {
+		return s.handler.Handle(ctx, base, data)
+	}
+	s.used = true
+
+	if err := addToBatch(s.handler, base, data, s.batch); err != nil {
+		logger.WithEventID(base.EventID).Warnf("Failed to add event to batch, writing it alone: %v", err)
+		s.ready <- false
+		<-s.written
+		return s.handler.Handle(ctx, base, data)
+	}
+
+	s.ready <- true
+	if err := <-s.written; err != nil {
+		return s.handler.Handle(ctx, base, data)
+	}
+	return nil
+}
+
+// processBatch writes a worker's accumulated messages through database
+// batches. Each message runs through the middleware chain like any other,
+// one at a time up to the point its handler joins the batch, so middleware
+// sees events in order and sees the outcome of the batch write. A message
+// whose handler cannot batch is processed on its own, after flushing the
+// events accumulated before it so per-key order holds.
+func (c *Consumer) processBatch(items []work) {
+	batch := &database.Batch{}
+	var pending []*batchSlot
+	flush := func() {
+		c.flushBatch(batch, pending)
+		batch, pending = &database.Batch{}, nil
+	}
+
+	for _, w := range items {
+		if w.parked != nil {
+			flush()
+			c.processWork(w)
+			continue
+		}
+		if c.ctx.Err() != nil || !c.offsets.current(w.key, w.msg.TopicPartition.Offset, w.epoch) {
+			continue
+		}
+
+		var base models.BaseEvent
+		var handler BatchHandler
+		if err := json.Unmarshal(w.msg.Value, &base); err == nil {
+			handler, _ = c.handlerFor(base.EventType).(BatchHandler)
+		}
+		if handler == nil {
+			flush()
+			c.processWork(w)
+			continue
+		}
+
+		slot := newBatchSlot(handler, batch)
+		go func(w work) {
+			defer close(slot.done)
+			if c.processMessage(w.msg, slot) {
+				c.offsets.done(w.key, w.msg.TopicPartition.Offset, w.epoch)
+			}
+		}(w)
+
+		// Middleware may finish the message without reaching the handler,
+		// e.g. for a duplicate or invalid event
+		select {
+		case joined := <-slot.ready:
+			if joined {
+				pending = append(pending, slot)
+				continue
+			}
+			flush()
+			slot.written <- nil
+			<-slot.done
+		case <-slot.done:
+		}
+	}
+
+	flush()
+}
+
+// flushBatch writes a batch in one transaction and passes the outcome to
+// its messages in order, waiting for each to finish. If the batch fails,
+// each message is written individually so that a single bad event cannot
+// hold back the others.
+func (c *Consumer) flushBatch(batch *database.Batch, slots []*batchSlot) {
+	if len(slots) == 0 {
+		return
+	}
+
+	metrics.BatchSize.Observe(float64(len(slots)))
+	err := c.writeBatch(batch)
+	if err != nil {
+		logger.Log.WithFields(logrus.Fields{
+			"size":  len(slots),
+			"error": err.Error(),
+		}).Warn("Batch write failed, processing events individually")
+	}
+
+	for _, slot := range slots {
+		slot.written <- err
+		<-slot.done
+	}
+}
+
+// addToBatch adds an event to batch, turning a panic into an error
+func addToBatch(bh BatchHandler, base models.BaseEvent, data []byte, batch *database.Batch) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = batchPanic(r, "AddToBatch panicked")
+		}
+	}()
+	return bh.AddToBatch(base, data, batch)
+}
+
+// writeBatch writes batch to the store, turning a panic into an error
+func (c *Consumer) writeBatch(batch *database.Batch) (err error) {
+	ctx, cancel := context.WithTimeout(c.ctx, 5*time.Second)
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			err = batchPanic(r, "WriteBatch panicked")
+		}
+	}()
+	return c.db.WriteBatch(ctx, batch)
+}
+
+// batchPanic logs a panic recovered on the batch path and returns it as an
+// error
+func batchPanic(r interface{}, msg string) error {
+	stack := debug.Stack()
+	logger.Log.WithField("stack", string(stack)).Errorf("%s: %v", msg, r)
+	return &PanicError{Value: r, Stack: stack}
+}
@@ -0,0 +1,218 @@
+package consumer_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"event-pipeline/internal/config"
+	"event-pipeline/internal/consumer"
+	"event-pipeline/internal/database"
+	"event-pipeline/internal/models"
+)
+
+func userEvent(t *testing.T, eventID string) []byte {
+	t.Helper()
+	data, err := json.Marshal(models.UserCreated{
+		BaseEvent: models.BaseEvent{EventID: eventID, EventType: models.UserCreatedEvent, Timestamp: time.Now()},
+		UserID:    "user-" + eventID,
+		Email:     eventID + "@example.com",
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal event: %v", err)
+	}
+	return data
+}
+
+// runBatched processes messages with batching enabled and waits for them
+// all to be committed
+func runBatched(t *testing.T, store *memoryStore, setup func(c *consumer.Consumer), values ...[]byte) *memorySource {
+	t.Helper()
+	source := newMemorySource(values...)
+	cfg := &config.KafkaConfig{Workers: 1, BatchSize: 10, BatchWindow: 200 * time.Millisecond, RetryMaxAttempts: 1}
+	c, err := consumer.NewWithSource(cfg, source, "events", store, &memoryDLQ{})
+	if err != nil {
+		t.Fatalf("Failed to create consumer: %v", err)
+	}
+	defer c.Stop()
+	if setup != nil {
+		setup(c)
+	}
+
+	go c.Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for {
+		source.mu.Lock()
+		committed := source.committed[0]
+		source.mu.Unlock()
+		if committed == int64(len(values)) || ctx.Err() != nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if report := c.Drain(ctx); !report.Completed {
+		t.Fatalf("Expected drain to complete, got %+v", report)
+	}
+	return source
+}
+
+func TestBatchFlushesBeforeUnbatchableEvent(t *testing.T) {
+	store := &memoryStore{}
+	custom := func(c *consumer.Consumer) {
+		c.Register("Custom", consumer.HandlerFunc(func(ctx context.Context, base models.BaseEvent, data []byte) error {
+			store.mu.Lock()
+			store.writes = append(store.writes, "custom:"+base.EventID)
+			store.mu.Unlock()
+			return nil
+		}))
+	}
+
+	source := runBatched(t, store, custom,
+		userEvent(t, "evt-1"),
+		userEvent(t, "evt-2"),
+		[]byte(`{"eventId":"evt-3","eventType":"Custom"}`),
+		userEvent(t, "evt-4"),
+	)
+
+	want := "batch:evt-1,evt-2 custom:evt-3 batch:evt-4"
+	if got := strings.Join(store.log(), " "); got != want {
+		t.Errorf("Expected writes %q, got %q", want, got)
+	}
+	if source.committed[0] != 4 {
+		t.Errorf("Expected commit at offset 4, got %d", source.committed[0])
+	}
+}
+
+func TestBatchFailureFallsBackToSingleWrites(t *testing.T) {
+	store := &memoryStore{batchErr: errors.New("deadlock victim")}
+
+	var values [][]byte
+	for i := 1; i <= 3; i++ {
+		values = append(values, userEvent(t, fmt.Sprintf("evt-%d", i)))
+	}
+	source := runBatched(t, store, nil, values...)
+
+	want := "user:evt-1 user:evt-2 user:evt-3"
+	if got := strings.Join(store.log(), " "); got != want {
+		t.Errorf("Expected events to be written one at a time, got %q", got)
+	}
+	if source.committed[0] != 3 {
+		t.Errorf("Expected commit at offset 3, got %d", source.committed[0])
+	}
+}
+
+func TestBatchWritePanicFallsBackToSingleWrites(t *testing.T) {
+	store := &memoryStore{batchPanics: true}
+	source := runBatched(t, store, nil, userEvent(t, "evt-1"), userEvent(t, "evt-2"))
+
+	want := "user:evt-1 user:evt-2"
+	if got := strings.Join(store.log(), " "); got != want {
+		t.Errorf("Expected events to be written one at a time, got %q", got)
+	}
+	if source.committed[0] != 2 {
+		t.Errorf("Expected commit at offset 2, got %d", source.committed[0])
+	}
+}
+
+// panickyBatchHandler panics when adding an event to a batch but handles
+// it on its own
+type panickyBatchHandler struct {
+	store *memoryStore
+}
+
+func (h panickyBatchHandler) Handle(ctx context.Context, base models.BaseEvent, data []byte) error {
+	h.store.mu.Lock()
+	defer h.store.mu.Unlock()
+	h.store.writes = append(h.store.writes, "custom:"+base.EventID)
+	return nil
+}
+
+func (h panickyBatchHandler) AddToBatch(base models.BaseEvent, data []byte, batch *database.Batch) error {
+	panic("cannot batch " + base.EventID)
+}
+
+func TestAddToBatchPanicProcessesEventIndividually(t *testing.T) {
+	store := &memoryStore{}
+	custom := func(c *consumer.Consumer) {
+		c.Register("Custom", panickyBatchHandler{store: store})
+	}
+
+	source := runBatched(t, store, custom,
+		userEvent(t, "evt-1"),
+		[]byte(`{"eventId":"evt-2","eventType":"Custom"}`),
+		userEvent(t, "evt-3"),
+	)
+
+	want := "batch:evt-1 custom:evt-2 batch:evt-3"
+	if got := strings.Join(store.log(), " "); got != want {
+		t.Errorf("Expected writes %q, got %q", want, got)
+	}
+	if source.committed[0] != 3 {
+		t.Errorf("Expected commit at offset 3, got %d", source.committed[0])
+	}
+}
+
+func TestBatchedEventsRunThroughMiddleware(t *testing.T) {
+	store := &memoryStore{}
+	record := func(c *consumer.Consumer) {
+		c.Use(func(next consumer.Handler) consumer.Handler {
+			return consumer.HandlerFunc(func(ctx context.Context, base models.BaseEvent, data []byte) error {
+				err := next.Handle(ctx, base, data)
+				store.mu.Lock()
+				store.writes = append(store.writes, fmt.Sprintf("done:%s:%v", base.EventID, err))
+				store.mu.Unlock()
+				return err
+			})
+		})
+	}
+
+	runBatched(t, store, record, userEvent(t, "evt-1"), userEvent(t, "evt-2"))
+
+	// Middleware sees each event in order, once the batch is written
+	want := "batch:evt-1,evt-2 done:evt-1:<nil> done:evt-2:<nil>"
+	if got := strings.Join(store.log(), " "); got != want {
+		t.Errorf("Expected writes %q, got %q", want, got)
+	}
+}
+
+func TestBatchedInvalidEventIsDeadLettered(t *testing.T) {
+	store := &memoryStore{}
+	source := newMemorySource(
+		userEvent(t, "evt-1"),
+		[]byte(`{"eventId":"evt-2","eventType":"UserCreated","email":"nope"}`),
+		userEvent(t, "evt-3"),
+	)
+	dlqClient := &memoryDLQ{}
+	cfg := &config.KafkaConfig{Workers: 1, BatchSize: 10, BatchWindow: 200 * time.Millisecond, RetryMaxAttempts: 1}
+	c, err := consumer.NewWithSource(cfg, source, "events", store, dlqClient)
+	if err != nil {
+		t.Fatalf("Failed to create consumer: %v", err)
+	}
+	defer c.Stop()
+	go c.Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for dlqClient.count() == 0 && ctx.Err() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if report := c.Drain(ctx); !report.Completed {
+		t.Fatalf("Expected drain to complete, got %+v", report)
+	}
+
+	if dlqClient.count() != 1 || len(dlqClient.entries[0].Fields) == 0 {
+		t.Errorf("Expected evt-2 to be dead-lettered with its invalid fields, got %+v", dlqClient.entries)
+	}
+	if got := strings.Join(store.log(), " "); got != "batch:evt-1,evt-3" {
+		t.Errorf("Expected the valid events to be batched, got %q", got)
+	}
+	if source.committed[0] != 3 {
+		t.Errorf("Expected commit at offset 3, got %d", source.committed[0])
+	}
+}
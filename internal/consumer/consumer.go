@@ -3,212 +3,416 @@ package consumer
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"runtime/debug"
+	"sync"
 	"time"
 
-	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"github.com/sirupsen/logrus"
 	"event-pipeline/internal/config"
 	"event-pipeline/internal/database"
-	"event-pipeline/internal/dlq"
 	"event-pipeline/internal/logger"
 	"event-pipeline/internal/metrics"
 	"event-pipeline/internal/models"
 )
 
-// Consumer wraps Kafka consumer
+// Consumer reads events from a Source and applies them to the Store
 type Consumer struct {
-	consumer *kafka.Consumer
-	db       *database.DB
-	dlq      *dlq.DLQ
-	ctx      context.Context
-	cancel   context.CancelFunc
+	source Source
+	topic  string
+	db     Store
+	dlq    DeadLetterQueue
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// Polling stops independently of ctx so in-flight work can drain
+	pollCtx    context.Context
+	pollCancel context.CancelFunc
+	pollDone   chan struct{}
+	closeOnce  sync.Once
+
+	// Admin operations run on the poll goroutine
+	control chan func()
+
+	mu         sync.RWMutex
+	handlers   map[models.EventType]Handler
+	fallback   Handler
+	middleware []Middleware
+	retry      RetryPolicy
+
+	// The last fatal source error while reconnecting, nil once connected
+	sourceErr  error
+	reconnects int
+
+	transientPause time.Duration
+
+	workers   int
+	queues    []chan work
+	wg        sync.WaitGroup
+	offsets   *offsetTracker
+	processed *rateCounter
+
+	batchSize   int
+	batchWindow time.Duration
+
+	assigned map[partitionKey]time.Time
+
+	// Partitions paused by an operator, and paused to back off after a
+	// transient failure or until a retry tier delay elapses
+	pausedAll bool
+	paused    map[partitionKey]bool
+	backoff   map[partitionKey]bool
+
+	// Retry topic routing; tier is -1 on the main topic
+	router *RetryRouter
+	tier   int
+
+	// Events missing a parent row wait here until it is written
+	parking     ParkingStore
+	parkTimeout time.Duration
+
+	// Parked events taken for replay wait here for the poll loop to route
+	// them to the workers owning their keys
+	replayMu     sync.Mutex
+	replays      []work
+	replayReady  chan struct{}
+	replayClosed bool
 }
 
-// New creates a new Kafka consumer
-func New(cfg *config.KafkaConfig, db *database.DB, dlqClient *dlq.DLQ) (*Consumer, error) {
-	c, err := kafka.NewConsumer(&kafka.ConfigMap{
-		"bootstrap.servers":  cfg.Brokers,
-		"group.id":           cfg.ConsumerGroup,
-		"auto.offset.reset":  "earliest",
-		"enable.auto.commit": false,
-	})
+// New creates a consumer reading the configured topic from Kafka
+func New(cfg *config.KafkaConfig, db Store, dlqClient DeadLetterQueue) (*Consumer, error) {
+	return newKafkaConsumer(cfg, cfg.Topic, cfg.ConsumerGroup, db, dlqClient)
+}
 
+// newKafkaConsumer creates a consumer subscribed to topic within group
+func newKafkaConsumer(cfg *config.KafkaConfig, topic, group string, db Store, dlqClient DeadLetterQueue) (*Consumer, error) {
+	source, err := NewKafkaSource(cfg, group)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create consumer: %w", err)
+		return nil, err
 	}
 
-	if err := c.Subscribe(cfg.Topic, nil); err != nil {
-		c.Close()
-		return nil, fmt.Errorf("failed to subscribe to topic: %w", err)
+	c, err := NewWithSource(cfg, source, topic, db, dlqClient)
+	if err != nil {
+		return nil, err
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-
 	logger.Log.WithFields(logrus.Fields{
-		"topic":         cfg.Topic,
-		"consumerGroup": cfg.ConsumerGroup,
+		"topic":              topic,
+		"consumerGroup":      group,
+		"assignmentStrategy": cfg.AssignmentStrategy,
 	}).Info("Successfully created Kafka consumer")
 
-	return &Consumer{
-		consumer: c,
+	return c, nil
+}
+
+// NewFromConfig creates a consumer for the transport selected in cfg
+func NewFromConfig(cfg *config.Config, db Store, dlqClient DeadLetterQueue) (*Consumer, error) {
+	var source Source
+	var err error
+
+	switch cfg.Transport {
+	case config.TransportRedis:
+		source, err = NewRedisSource(&cfg.Redis, cfg.Kafka.ConsumerGroup)
+	case config.TransportFile:
+		source, err = NewFileSource(&cfg.File, cfg.Kafka.ConsumerGroup)
+	default:
+		return New(&cfg.Kafka, db, dlqClient)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewWithSource(&cfg.Kafka, source, cfg.Kafka.Topic, db, dlqClient)
+}
+
+// NewWithSource creates a consumer reading topic from source. The source is
+// closed if subscribing fails, and by Stop otherwise.
+func NewWithSource(cfg *config.KafkaConfig, source Source, topic string, db Store, dlqClient DeadLetterQueue) (*Consumer, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	pollCtx, pollCancel := context.WithCancel(ctx)
+
+	cons := &Consumer{
+		source:   source,
+		topic:    topic,
 		db:       db,
 		dlq:      dlqClient,
 		ctx:      ctx,
 		cancel:   cancel,
-	}, nil
+		handlers: make(map[models.EventType]Handler),
+		retry:    RetryPolicyFromConfig(cfg),
+
+		pollCtx:    pollCtx,
+		pollCancel: pollCancel,
+		control:    make(chan func()),
+
+		replayReady: make(chan struct{}, 1),
+
+		transientPause: cfg.TransientPause,
+
+		workers:   cfg.Workers,
+		offsets:   newOffsetTracker(),
+		processed: newRateCounter(),
+
+		batchSize:   cfg.BatchSize,
+		batchWindow: cfg.BatchWindow,
+
+		assigned: make(map[partitionKey]time.Time),
+		paused:   make(map[partitionKey]bool),
+		backoff:  make(map[partitionKey]bool),
+		tier:     -1,
+	}
+	if cons.workers < 1 {
+		cons.workers = 1
+	}
+	cons.registerDefaultHandlers()
+
+	err := source.Subscribe(topic, RebalanceCallbacks{
+		Assigned: cons.onAssigned,
+		Revoked:  cons.onRevoked,
+	})
+	if err != nil {
+		cancel()
+		source.Close()
+		return nil, fmt.Errorf("failed to subscribe to topic: %w", err)
+	}
+
+	return cons, nil
 }
 
-// Start starts consuming messages
+// Start starts consuming messages and blocks until polling stops
 func (c *Consumer) Start() {
 	logger.Log.Info("Starting consumer...")
-	
+
+	c.mu.Lock()
+	c.pollDone = make(chan struct{})
+	c.mu.Unlock()
+	defer close(c.pollDone)
+
+	c.startWorkers()
+	defer c.stopWorkers()
+	c.openReplays()
+	defer c.closeReplays()
+	metrics.ConsumerConnected.WithLabelValues(c.topic).Set(1)
+
+	// Retry tier consumers share the store, so only the main consumer sweeps it
+	if c.parking != nil && c.tier < 0 {
+		c.wg.Add(1)
+		go c.sweepParked()
+	}
+
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
-	processedCount := make(map[string]int)
-
-	go func() {
-		for range ticker.C {
-			for eventType, count := range processedCount {
-				if count > 0 {
-					metrics.MessagesProcessedPerSecond.WithLabelValues(eventType).Set(float64(count))
-					processedCount[eventType] = 0
-				}
-			}
-		}
-	}()
+	commitTicker := time.NewTicker(commitInterval)
+	defer commitTicker.Stop()
 
 	for {
 		select {
-		case <-c.ctx.Done():
+		case <-c.pollCtx.Done():
 			logger.Log.Info("Consumer stopping...")
 			return
+		case <-ticker.C:
+			c.processed.flush()
+		case <-commitTicker.C:
+			c.safely(c.commitOffsets)
+		case fn := <-c.control:
+			fn()
+		case <-c.replayReady:
+			c.routeReplays()
 		default:
-			msg, err := c.consumer.ReadMessage(100 * time.Millisecond)
-			if err != nil {
-				if err.(kafka.Error).Code() == kafka.ErrTimedOut {
-					continue
-				}
-				logger.Log.Errorf("Consumer error: %v", err)
-				continue
-			}
-
-			c.processMessage(msg, processedCount)
+			c.safely(c.poll)
 		}
 	}
 }
 
-// Stop stops the consumer
-func (c *Consumer) Stop() {
-	c.cancel()
-	c.consumer.Close()
+// poll reads one message and hands it to a worker
+func (c *Consumer) poll() {
+	msg, err := c.source.Read(100 * time.Millisecond)
+
+	var fatal *FatalError
+	switch {
+	case errors.As(err, &fatal):
+		c.reconnect(err)
+		return
+	case err != nil:
+		logger.Log.Errorf("Consumer error: %v", err)
+		return
+	}
+	c.setSourceErr(nil)
+
+	if msg == nil || c.deferUntilDue(msg) {
+		return
+	}
+	c.dispatch(msg)
 }
 
-// processMessage processes a single Kafka message
-func (c *Consumer) processMessage(msg *kafka.Message, processedCount map[string]int) {
-	start := time.Now()
+// safely runs a step of the poll loop, recovering from a panic so the loop
+// keeps running. It backs off briefly so a repeating panic cannot spin.
+func (c *Consumer) safely(step func()) {
 	defer func() {
-		metrics.KafkaConsumeLatency.Observe(time.Since(start).Seconds())
+		if r := recover(); r != nil {
+			logger.Log.WithField("stack", string(debug.Stack())).Errorf("Poll loop panicked: %v", r)
+			metrics.PollLoopPanics.WithLabelValues(c.topic).Inc()
+
+			select {
+			case <-c.pollCtx.Done():
+			case <-time.After(pollPanicBackoff):
+			}
+		}
 	}()
+	step()
+}
 
+// Stop stops the consumer immediately, abandoning in-flight work. Use Drain
+// first for a graceful shutdown.
+func (c *Consumer) Stop() {
+	c.cancel()
+	c.waitPollLoop(context.Background())
+	c.closeOnce.Do(func() {
+		c.source.Close()
+	})
+}
+
+// waitPollLoop waits for a running poll loop to exit or ctx to expire
+func (c *Consumer) waitPollLoop(ctx context.Context) bool {
+	c.mu.RLock()
+	done := c.pollDone
+	c.mu.RUnlock()
+
+	if done == nil {
+		return true
+	}
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// processMessage processes a single message with handler, or the handler
+// registered for its event type when handler is nil. It returns true when
+// the message is finished with and its offset may be committed.
+func (c *Consumer) processMessage(msg *Message, handler Handler) bool {
 	// Parse base event to determine type
 	var baseEvent models.BaseEvent
 	if err := json.Unmarshal(msg.Value, &baseEvent); err != nil {
 		logger.Log.Errorf("Failed to parse base event: %v", err)
-		c.sendToDLQ(baseEvent.EventID, string(msg.Value), fmt.Sprintf("Failed to parse base event: %v", err))
-		c.consumer.CommitMessage(msg)
-		return
+		c.sendToDLQ(baseEvent.EventID, string(msg.Value), fmt.Sprintf("Failed to parse base event: %v", err), 0)
+		return true
 	}
 
-	// Route by event type
-	var err error
-	switch baseEvent.EventType {
-	case models.UserCreatedEvent:
-		err = c.handleUserCreated(msg.Value, baseEvent.EventID)
-	case models.OrderPlacedEvent:
-		err = c.handleOrderPlaced(msg.Value, baseEvent.EventID)
-	case models.PaymentSettledEvent:
-		err = c.handlePaymentSettled(msg.Value, baseEvent.EventID)
-	case models.InventoryAdjustedEvent:
-		err = c.handleInventoryAdjusted(msg.Value, baseEvent.EventID)
-	default:
-		err = fmt.Errorf("unknown event type: %s", baseEvent.EventType)
+	if handler == nil {
+		handler = c.handlerFor(baseEvent.EventType)
+	}
+	attempts, err := c.handleWithRetry(withHeaders(c.ctx, msg.Headers), handler, baseEvent, msg.Value)
+
+	// Invalid events would fail the same way on every attempt
+	var invalid *models.ValidationError
+	if errors.As(err, &invalid) {
+		logger.WithEventID(baseEvent.EventID).Errorf("Event failed validation: %v", err)
+		c.sendInvalidToDLQ(baseEvent.EventID, string(msg.Value), invalid)
+		metrics.MessagesProcessed.WithLabelValues(string(baseEvent.EventType), "invalid").Inc()
+		return true
 	}
 
-	if err != nil {
-		logger.WithEventID(baseEvent.EventID).Errorf("Failed to process event: %v", err)
-		c.sendToDLQ(baseEvent.EventID, string(msg.Value), err.Error())
-		metrics.MessagesProcessed.WithLabelValues(string(baseEvent.EventType), "error").Inc()
-	} else {
-		metrics.MessagesProcessed.WithLabelValues(string(baseEvent.EventType), "success").Inc()
-		processedCount[string(baseEvent.EventType)]++
+	if err != nil && c.ctx.Err() != nil {
+		// Shutting down mid-retry: leave the offset uncommitted for redelivery
+		logger.WithEventID(baseEvent.EventID).Warnf("Consumer stopped before event was processed: %v", err)
+		return false
 	}
 
-	// Commit offset
-	if _, err := c.consumer.CommitMessage(msg); err != nil {
-		logger.Log.Errorf("Failed to commit offset: %v", err)
+	var missing *database.MissingParentError
+	if err != nil && errors.As(err, &missing) && c.park(msg, baseEvent, missing) {
+		// The event is replayed once its parent is written
+		logger.WithEventID(baseEvent.EventID).Warnf("Parent %s missing after %d attempts, event parked", missing.Parent, attempts)
+		metrics.MessagesProcessed.WithLabelValues(string(baseEvent.EventType), "parked").Inc()
+		return true
 	}
-}
 
-// handleUserCreated processes UserCreated event
-func (c *Consumer) handleUserCreated(data []byte, eventID string) error {
-	var event models.UserCreated
-	if err := json.Unmarshal(data, &event); err != nil {
-		return fmt.Errorf("failed to unmarshal UserCreated event: %w", err)
+	if err != nil && Classify(err) == ErrorTransient {
+		// Transient failures are redelivered rather than dead-lettered
+		logger.WithEventID(baseEvent.EventID).Warnf("Transient failure after %d attempts, pausing partition: %v", attempts, err)
+		metrics.MessagesProcessed.WithLabelValues(string(baseEvent.EventType), "transient").Inc()
+		metrics.PartitionPauses.WithLabelValues(string(baseEvent.EventType)).Inc()
+		c.pausePartition(msg, c.transientPause)
+		return false
 	}
 
-	ctx, cancel := context.WithTimeout(c.ctx, 5*time.Second)
-	defer cancel()
+	if err != nil {
+		logger.WithEventID(baseEvent.EventID).WithFields(logrus.Fields{
+			"attempts": attempts,
+			"class":    Classify(err).String(),
+		}).Errorf("Failed to process event: %v", err)
+		c.fail(msg, baseEvent, err, attempts)
+		metrics.MessagesProcessed.WithLabelValues(string(baseEvent.EventType), "error").Inc()
+	} else {
+		metrics.MessagesProcessed.WithLabelValues(string(baseEvent.EventType), "success").Inc()
+		c.processed.inc(string(baseEvent.EventType))
+		c.replayChildren(baseEvent, msg.Value)
+	}
 
-	return c.db.UpsertUser(ctx, event)
+	return true
 }
 
-// handleOrderPlaced processes OrderPlaced event
-func (c *Consumer) handleOrderPlaced(data []byte, eventID string) error {
-	var event models.OrderPlaced
-	if err := json.Unmarshal(data, &event); err != nil {
-		return fmt.Errorf("failed to unmarshal OrderPlaced event: %w", err)
-	}
+// pausePartition rewinds the message's partition to the message's offset and
+// pauses it, resuming once d has elapsed. In-flight work for later offsets of
+// the partition is discarded so per-key order is preserved.
+func (c *Consumer) pausePartition(msg *Message, d time.Duration) {
+	tp := msg.TopicPartition
+	partitions := []TopicPartition{tp}
 
-	ctx, cancel := context.WithTimeout(c.ctx, 5*time.Second)
-	defer cancel()
+	if !c.offsets.rewind(keyOf(tp), tp.Offset) {
+		// Already rewound to an earlier offset; this message will be redelivered
+		return
+	}
 
-	return c.db.UpsertOrder(ctx, event)
-}
+	key := keyOf(tp)
+	c.mu.Lock()
+	c.backoff[key] = true
+	c.mu.Unlock()
 
-// handlePaymentSettled processes PaymentSettled event
-func (c *Consumer) handlePaymentSettled(data []byte, eventID string) error {
-	var event models.PaymentSettled
-	if err := json.Unmarshal(data, &event); err != nil {
-		return fmt.Errorf("failed to unmarshal PaymentSettled event: %w", err)
+	if err := c.source.Pause(partitions); err != nil {
+		logger.Log.Errorf("Failed to pause partition %d: %v", tp.Partition, err)
+	}
+	if err := c.source.Seek(tp); err != nil {
+		logger.Log.Errorf("Failed to rewind partition %d to offset %d: %v", tp.Partition, tp.Offset, err)
 	}
 
-	ctx, cancel := context.WithTimeout(c.ctx, 5*time.Second)
-	defer cancel()
+	time.AfterFunc(d, func() {
+		c.mu.Lock()
+		delete(c.backoff, key)
+		c.mu.Unlock()
 
-	return c.db.UpsertPayment(ctx, event)
+		// Partitions paused by an operator stay paused
+		if c.ctx.Err() != nil || c.operatorPaused(key) {
+			return
+		}
+		if err := c.source.Resume(partitions); err != nil {
+			logger.Log.Errorf("Failed to resume partition %d: %v", tp.Partition, err)
+			return
+		}
+		logger.Log.Infof("Resumed partition %d at offset %d", tp.Partition, tp.Offset)
+	})
 }
 
-// handleInventoryAdjusted processes InventoryAdjusted event
-func (c *Consumer) handleInventoryAdjusted(data []byte, eventID string) error {
-	var event models.InventoryAdjusted
-	if err := json.Unmarshal(data, &event); err != nil {
-		return fmt.Errorf("failed to unmarshal InventoryAdjusted event: %w", err)
-	}
-
-	ctx, cancel := context.WithTimeout(c.ctx, 5*time.Second)
+// sendToDLQ sends a failed message to the dead letter queue
+func (c *Consumer) sendToDLQ(eventID, originalData, errorMsg string, retryCount int) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	return c.db.UpsertInventory(ctx, event)
+	if err := c.dlq.Push(ctx, eventID, originalData, errorMsg, retryCount); err != nil {
+		logger.WithEventID(eventID).Errorf("Failed to push to DLQ: %v", err)
+	}
 }
 
-// sendToDLQ sends a failed message to the dead letter queue
-func (c *Consumer) sendToDLQ(eventID, originalData, errorMsg string) {
+// sendInvalidToDLQ sends a message that failed validation to the dead letter
+// queue with its invalid fields
+func (c *Consumer) sendInvalidToDLQ(eventID, originalData string, verr *models.ValidationError) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	if err := c.dlq.Push(ctx, eventID, originalData, errorMsg); err != nil {
+	if err := c.dlq.PushInvalid(ctx, eventID, originalData, verr); err != nil {
 		logger.WithEventID(eventID).Errorf("Failed to push to DLQ: %v", err)
 	}
 }
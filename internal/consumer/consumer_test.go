@@ -0,0 +1,202 @@
+package consumer_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"event-pipeline/internal/config"
+	"event-pipeline/internal/consumer"
+	"event-pipeline/internal/database"
+	"event-pipeline/internal/models"
+)
+
+// memorySource serves queued messages from a single partition
+type memorySource struct {
+	mu        sync.Mutex
+	messages  []*consumer.Message
+	committed map[int32]int64
+}
+
+func newMemorySource(values ...[]byte) *memorySource {
+	s := &memorySource{committed: make(map[int32]int64)}
+	for i, v := range values {
+		s.messages = append(s.messages, &consumer.Message{
+			TopicPartition: consumer.TopicPartition{Topic: "events", Partition: 0, Offset: int64(i)},
+			Value:          v,
+			Timestamp:      time.Now(),
+		})
+	}
+	return s
+}
+
+func (s *memorySource) Subscribe(topic string, cb consumer.RebalanceCallbacks) error {
+	cb.Assigned([]consumer.TopicPartition{{Topic: topic, Partition: 0}})
+	return nil
+}
+
+func (s *memorySource) Read(timeout time.Duration) (*consumer.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.messages) == 0 {
+		return nil, nil
+	}
+	msg := s.messages[0]
+	s.messages = s.messages[1:]
+	return msg, nil
+}
+
+func (s *memorySource) Commit(offsets []consumer.TopicPartition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, tp := range offsets {
+		s.committed[tp.Partition] = tp.Offset
+	}
+	return nil
+}
+
+func (s *memorySource) Assignment() ([]consumer.TopicPartition, error) {
+	return []consumer.TopicPartition{{Topic: "events", Partition: 0}}, nil
+}
+
+func (s *memorySource) Pause(partitions []consumer.TopicPartition) error  { return nil }
+func (s *memorySource) Resume(partitions []consumer.TopicPartition) error { return nil }
+func (s *memorySource) Seek(tp consumer.TopicPartition) error             { return nil }
+func (s *memorySource) Close() error                                      { return nil }
+
+func (s *memorySource) OffsetForTime(tp consumer.TopicPartition, t time.Time) (int64, error) {
+	return 0, nil
+}
+
+// memoryStore records the users written to it, and the order of its
+// writes as "user:<eventId>" and "batch:<eventIds>"
+type memoryStore struct {
+	mu       sync.Mutex
+	users    []models.UserCreated
+	writes   []string
+	batchErr error
+
+	// WriteBatch panics when set
+	batchPanics bool
+}
+
+func (m *memoryStore) UpsertUser(ctx context.Context, event models.UserCreated) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users = append(m.users, event)
+	m.writes = append(m.writes, "user:"+event.EventID)
+	return nil
+}
+
+func (m *memoryStore) UpsertOrder(ctx context.Context, event models.OrderPlaced) error { return nil }
+func (m *memoryStore) UpsertPayment(ctx context.Context, event models.PaymentSettled) error {
+	return nil
+}
+func (m *memoryStore) UpsertInventory(ctx context.Context, event models.InventoryAdjusted) error {
+	return nil
+}
+
+func (m *memoryStore) WriteBatch(ctx context.Context, b *database.Batch) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.batchPanics {
+		panic("batch write panicked")
+	}
+	if m.batchErr != nil {
+		return m.batchErr
+	}
+	ids := make([]string, 0, len(b.Users))
+	for _, u := range b.Users {
+		ids = append(ids, u.EventID)
+	}
+	m.writes = append(m.writes, "batch:"+strings.Join(ids, ","))
+	return nil
+}
+
+func (m *memoryStore) log() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.writes...)
+}
+
+// memoryDLQ records dead-lettered entries
+type memoryDLQ struct {
+	mu      sync.Mutex
+	entries []models.DLQEntry
+}
+
+func (d *memoryDLQ) Push(ctx context.Context, eventID, originalData, errorMsg string, retryCount int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries = append(d.entries, models.DLQEntry{EventID: eventID, OriginalData: originalData, Error: errorMsg})
+	return nil
+}
+
+func (d *memoryDLQ) PushInvalid(ctx context.Context, eventID, originalData string, verr *models.ValidationError) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries = append(d.entries, models.DLQEntry{EventID: eventID, OriginalData: originalData, Error: verr.Error(), Fields: verr.Fields})
+	return nil
+}
+
+func (d *memoryDLQ) count() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.entries)
+}
+
+func TestConsumerWithMemorySource(t *testing.T) {
+	user, err := json.Marshal(models.UserCreated{
+		BaseEvent: models.BaseEvent{EventID: "evt-1", EventType: models.UserCreatedEvent, Timestamp: time.Now()},
+		UserID:    "user-1",
+		Email:     "test@example.com",
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal event: %v", err)
+	}
+	unknown := []byte(`{"eventId":"evt-2","eventType":"Unknown"}`)
+	malformed := []byte(`{"eventId":`)
+	invalid := []byte(`{"eventId":"evt-4","eventType":"UserCreated","email":"nope"}`)
+
+	source := newMemorySource(user, unknown, malformed, invalid)
+	store := &memoryStore{}
+	dlqClient := &memoryDLQ{}
+
+	cfg := &config.KafkaConfig{Workers: 2, BatchSize: 1, RetryMaxAttempts: 1}
+	c, err := consumer.NewWithSource(cfg, source, "events", store, dlqClient)
+	if err != nil {
+		t.Fatalf("Failed to create consumer: %v", err)
+	}
+	defer c.Stop()
+
+	go c.Start()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for dlqClient.count() < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if report := c.Drain(ctx); !report.Completed {
+		t.Fatalf("Expected drain to complete, got %+v", report)
+	}
+
+	if len(store.users) != 1 || store.users[0].UserID != "user-1" {
+		t.Errorf("Expected user-1 to be stored, got %v", store.users)
+	}
+	if dlqClient.count() != 3 {
+		t.Errorf("Expected 3 dead-lettered messages, got %d", dlqClient.count())
+	}
+	for _, entry := range dlqClient.entries {
+		if entry.EventID == "evt-4" && len(entry.Fields) != 2 {
+			t.Errorf("Expected userId and email to be reported invalid, got %+v", entry.Fields)
+		}
+	}
+	if source.committed[0] != 4 {
+		t.Errorf("Expected commit at offset 4, got %d", source.committed[0])
+	}
+}
@@ -0,0 +1,109 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"event-pipeline/internal/config"
+	"event-pipeline/internal/dedup"
+	"event-pipeline/internal/logger"
+	"event-pipeline/internal/metrics"
+	"event-pipeline/internal/models"
+)
+
+// dedupHold bounds how long the claim of a consumer that died mid-event
+// holds back redeliveries of the event
+const dedupHold = time.Minute
+
+// dedupTimeout bounds recording the outcome of an event in the filter
+const dedupTimeout = 2 * time.Second
+
+// ErrInProgress is returned for an event whose ID is held by another delivery
+var ErrInProgress = errors.New("event is already being processed")
+
+// Deduplicator remembers recently processed event IDs
+type Deduplicator interface {
+	Claim(ctx context.Context, eventID string, hold time.Duration) (dedup.Status, error)
+	Complete(ctx context.Context, eventID string, ttl time.Duration) error
+	Release(ctx context.Context, eventID string) error
+}
+
+// DedupPolicy sets the duplicate window per event type and what to do while
+// the filter is unavailable
+type DedupPolicy struct {
+	TTL      time.Duration                      // window for event types without their own
+	TTLs     map[models.EventType]time.Duration // windows per event type
+	Fallback string                             // config.DedupFallbackProcess or config.DedupFallbackRetry
+}
+
+// DedupPolicyFromConfig builds a DedupPolicy from Kafka configuration
+func DedupPolicyFromConfig(cfg *config.KafkaConfig) DedupPolicy {
+	ttls := make(map[models.EventType]time.Duration, len(cfg.DedupTTLs))
+	for eventType, ttl := range cfg.DedupTTLs {
+		ttls[models.EventType(eventType)] = ttl
+	}
+	return DedupPolicy{TTL: cfg.DedupTTL, TTLs: ttls, Fallback: cfg.DedupFallback}
+}
+
+// Window returns the duplicate window of an event type; zero disables the filter
+func (p DedupPolicy) Window(eventType models.EventType) time.Duration {
+	if ttl, ok := p.TTLs[eventType]; ok {
+		return ttl
+	}
+	return p.TTL
+}
+
+// Dedup skips events whose ID was processed within the policy's window. An
+// event whose ID is held by another delivery fails transiently so it is
+// redelivered later. If the filter cannot be reached, events are processed
+// unfiltered or failed transiently according to the policy's fallback.
+func Dedup(filter Deduplicator, policy DedupPolicy) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, base models.BaseEvent, data []byte) error {
+			window := policy.Window(base.EventType)
+			if window <= 0 || base.EventID == "" {
+				return next.Handle(ctx, base, data)
+			}
+			eventType := string(base.EventType)
+
+			status, err := filter.Claim(ctx, base.EventID, dedupHold)
+			if err != nil {
+				metrics.DedupChecks.WithLabelValues(eventType, "error").Inc()
+				if policy.Fallback == config.DedupFallbackRetry {
+					return Transient(fmt.Errorf("duplicate filter unavailable: %w", err))
+				}
+				logger.WithEventID(base.EventID).Warnf("Duplicate filter unavailable, processing unfiltered: %v", err)
+				return next.Handle(ctx, base, data)
+			}
+
+			switch status {
+			case dedup.Duplicate:
+				metrics.DedupChecks.WithLabelValues(eventType, "hit").Inc()
+				logger.WithEventID(base.EventID).Info("Duplicate event skipped")
+				return nil
+			case dedup.InProgress:
+				metrics.DedupChecks.WithLabelValues(eventType, "in_progress").Inc()
+				return Transient(ErrInProgress)
+			}
+			metrics.DedupChecks.WithLabelValues(eventType, "miss").Inc()
+
+			err = next.Handle(ctx, base, data)
+
+			// Record the outcome even if the handler used up ctx
+			rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), dedupTimeout)
+			defer cancel()
+			if err != nil {
+				if rerr := filter.Release(rctx, base.EventID); rerr != nil {
+					logger.WithEventID(base.EventID).Warnf("Failed to release event ID: %v", rerr)
+				}
+				return err
+			}
+			if cerr := filter.Complete(rctx, base.EventID, window); cerr != nil {
+				logger.WithEventID(base.EventID).Warnf("Failed to record event ID: %v", cerr)
+			}
+			return nil
+		})
+	}
+}
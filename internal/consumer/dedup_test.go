@@ -0,0 +1,147 @@
+package consumer_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"event-pipeline/internal/config"
+	"event-pipeline/internal/consumer"
+	"event-pipeline/internal/dedup"
+	"event-pipeline/internal/models"
+)
+
+// memoryFilter is a Deduplicator holding IDs in a map
+type memoryFilter struct {
+	ids  map[string]string
+	ttls map[string]time.Duration
+	err  error
+}
+
+func newMemoryFilter() *memoryFilter {
+	return &memoryFilter{ids: make(map[string]string), ttls: make(map[string]time.Duration)}
+}
+
+func (f *memoryFilter) Claim(ctx context.Context, eventID string, hold time.Duration) (dedup.Status, error) {
+	if f.err != nil {
+		return dedup.Claimed, f.err
+	}
+	switch f.ids[eventID] {
+	case "done":
+		return dedup.Duplicate, nil
+	case "pending":
+		return dedup.InProgress, nil
+	}
+	f.ids[eventID] = "pending"
+	return dedup.Claimed, nil
+}
+
+func (f *memoryFilter) Complete(ctx context.Context, eventID string, ttl time.Duration) error {
+	f.ids[eventID] = "done"
+	f.ttls[eventID] = ttl
+	return nil
+}
+
+func (f *memoryFilter) Release(ctx context.Context, eventID string) error {
+	delete(f.ids, eventID)
+	return nil
+}
+
+func TestDedupSkipsRecentEvents(t *testing.T) {
+	filter := newMemoryFilter()
+	policy := consumer.DedupPolicy{
+		TTL:  time.Hour,
+		TTLs: map[models.EventType]time.Duration{models.InventoryAdjustedEvent: 0},
+	}
+
+	calls := 0
+	fail := errors.New("database down")
+	var result error
+	handler := consumer.Dedup(filter, policy)(consumer.HandlerFunc(
+		func(ctx context.Context, base models.BaseEvent, data []byte) error {
+			calls++
+			return result
+		}))
+
+	ctx := context.Background()
+	user := models.BaseEvent{EventID: "evt-1", EventType: models.UserCreatedEvent}
+
+	// A failed attempt releases the ID so the event can be retried
+	result = fail
+	if err := handler.Handle(ctx, user, nil); !errors.Is(err, fail) {
+		t.Fatalf("Expected the handler error, got %v", err)
+	}
+	result = nil
+	if err := handler.Handle(ctx, user, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := handler.Handle(ctx, user, nil); err != nil {
+		t.Fatalf("Unexpected error on duplicate: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected the duplicate to be skipped, handler called %d times", calls)
+	}
+	if filter.ttls["evt-1"] != time.Hour {
+		t.Errorf("Expected a 1h window, got %s", filter.ttls["evt-1"])
+	}
+
+	// Another delivery holding the ID is retried later
+	filter.ids["evt-2"] = "pending"
+	err := handler.Handle(ctx, models.BaseEvent{EventID: "evt-2", EventType: models.UserCreatedEvent}, nil)
+	if !errors.Is(err, consumer.ErrInProgress) || consumer.Classify(err) != consumer.ErrorTransient {
+		t.Errorf("Expected a transient in-progress error, got %v", err)
+	}
+
+	// Event types with a zero window are not filtered
+	inventory := models.BaseEvent{EventID: "evt-3", EventType: models.InventoryAdjustedEvent}
+	handler.Handle(ctx, inventory, nil)
+	handler.Handle(ctx, inventory, nil)
+	if calls != 4 {
+		t.Errorf("Expected unfiltered events to be handled twice, handler called %d times", calls)
+	}
+}
+
+func TestDedupFallback(t *testing.T) {
+	filter := newMemoryFilter()
+	filter.err = errors.New("connection refused")
+
+	calls := 0
+	next := consumer.HandlerFunc(func(ctx context.Context, base models.BaseEvent, data []byte) error {
+		calls++
+		return nil
+	})
+	base := models.BaseEvent{EventID: "evt-1", EventType: models.UserCreatedEvent}
+
+	process := consumer.Dedup(filter, consumer.DedupPolicy{TTL: time.Hour, Fallback: config.DedupFallbackProcess})(next)
+	if err := process.Handle(context.Background(), base, nil); err != nil || calls != 1 {
+		t.Errorf("Expected the event to be processed unfiltered, got %v after %d calls", err, calls)
+	}
+
+	retry := consumer.Dedup(filter, consumer.DedupPolicy{TTL: time.Hour, Fallback: config.DedupFallbackRetry})(next)
+	if err := retry.Handle(context.Background(), base, nil); consumer.Classify(err) != consumer.ErrorTransient || calls != 1 {
+		t.Errorf("Expected a transient error without processing, got %v after %d calls", err, calls)
+	}
+}
+
+func TestDedupFiltersBatchedEvents(t *testing.T) {
+	filter := newMemoryFilter()
+	filter.ids["evt-1"] = "done"
+	store := &memoryStore{}
+	withFilter := func(c *consumer.Consumer) {
+		c.Use(consumer.Dedup(filter, consumer.DedupPolicy{TTL: time.Hour}))
+	}
+
+	source := runBatched(t, store, withFilter, userEvent(t, "evt-1"), userEvent(t, "evt-2"), userEvent(t, "evt-3"))
+
+	if got := strings.Join(store.log(), " "); got != "batch:evt-2,evt-3" {
+		t.Errorf("Expected the duplicate to be skipped from the batch, got %q", got)
+	}
+	if filter.ids["evt-2"] != "done" || filter.ids["evt-3"] != "done" {
+		t.Errorf("Expected the batched events to be recorded once written, got %v", filter.ids)
+	}
+	if source.committed[0] != 3 {
+		t.Errorf("Expected commit at offset 3, got %d", source.committed[0])
+	}
+}
@@ -0,0 +1,82 @@
+package consumer
+
+import (
+	"context"
+	"time"
+
+	"event-pipeline/internal/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+// PartitionBacklog describes work left on a partition
+type PartitionBacklog struct {
+	Topic     string `json:"topic"`
+	Partition int32  `json:"partition"`
+	Pending   int    `json:"pending"`
+	Committed int64  `json:"committedOffset"`
+}
+
+// DrainReport summarises a graceful shutdown
+type DrainReport struct {
+	Completed   bool               `json:"completed"`
+	Unprocessed int                `json:"unprocessed"`
+	Partitions  []PartitionBacklog `json:"partitions"`
+	Duration    time.Duration      `json:"duration"`
+}
+
+// Drain stops polling, waits for in-flight and queued messages to finish
+// and commits the final offsets. If ctx expires first, remaining handlers are
+// cancelled and their messages are left uncommitted for redelivery. Call Stop
+// afterwards to close the Kafka client.
+func (c *Consumer) Drain(ctx context.Context) DrainReport {
+	start := time.Now()
+	logger.Log.Info("Draining consumer...")
+
+	c.pollCancel()
+	completed := c.waitPollLoop(ctx)
+
+	if completed {
+		done := make(chan struct{})
+		go func() {
+			c.wg.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			completed = false
+		}
+	}
+
+	if !completed {
+		// Abort remaining handlers; their offsets stay uncommitted
+		c.cancel()
+		c.wg.Wait()
+	}
+
+	c.commitOffsets()
+
+	report := DrainReport{
+		Completed:  completed,
+		Partitions: c.offsets.backlog(),
+		Duration:   time.Since(start),
+	}
+	for _, p := range report.Partitions {
+		report.Unprocessed += p.Pending
+	}
+
+	entry := logger.Log.WithFields(logrus.Fields{
+		"completed":   report.Completed,
+		"unprocessed": report.Unprocessed,
+		"duration":    report.Duration.String(),
+	})
+	if report.Unprocessed > 0 {
+		entry.Warn("Consumer drained with unprocessed messages")
+	} else {
+		entry.Info("Consumer drained")
+	}
+
+	return report
+}
@@ -0,0 +1,154 @@
+package consumer
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+)
+
+// ErrorClass describes how the consumer reacts to a handler failure
+type ErrorClass int
+
+const (
+	// ErrorUnknown failures are retried under the retry policy, then dead-lettered
+	ErrorUnknown ErrorClass = iota
+	// ErrorPermanent failures can never succeed and are dead-lettered immediately
+	ErrorPermanent
+	// ErrorTransient failures are retried, then the partition is paused and
+	// rewound without committing the offset
+	ErrorTransient
+)
+
+// String returns the class name used in logs and metrics
+func (c ErrorClass) String() string {
+	switch c {
+	case ErrorPermanent:
+		return "permanent"
+	case ErrorTransient:
+		return "transient"
+	default:
+		return "unknown"
+	}
+}
+
+// PermanentError marks a failure that retrying cannot fix
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string { return e.Err.Error() }
+func (e *PermanentError) Unwrap() error { return e.Err }
+
+// TransientError marks a failure that is expected to succeed on redelivery
+type TransientError struct {
+	Err error
+}
+
+func (e *TransientError) Error() string { return e.Err.Error() }
+func (e *TransientError) Unwrap() error { return e.Err }
+
+// Permanent wraps err so the consumer dead-letters it without retrying
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// Transient wraps err so the consumer retries it without committing the offset
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &TransientError{Err: err}
+}
+
+// SQL Server error numbers that indicate a temporary condition
+var transientSQLErrors = map[int32]bool{
+	-2:    true, // client timeout
+	233:   true, // connection closed by server
+	1205:  true, // deadlock victim
+	1222:  true, // lock request timeout
+	4060:  true, // cannot open database
+	10053: true, // transport-level error
+	10054: true, // connection reset by peer
+	10060: true, // network timeout
+	10928: true, // resource limit reached
+	10929: true, // server too busy
+	40197: true, // service error processing request
+	40501: true, // service busy
+	40613: true, // database unavailable
+}
+
+// SQL Server error numbers that indicate the data itself is unacceptable
+var permanentSQLErrors = map[int32]bool{
+	245:  true, // conversion failed
+	515:  true, // NULL into non-nullable column
+	2601: true, // duplicate key in unique index
+	2627: true, // unique constraint violation
+	8114: true, // error converting data type
+	8152: true, // string or binary data would be truncated
+}
+
+// Classify determines how a handler error should be treated
+func Classify(err error) ErrorClass {
+	if err == nil {
+		return ErrorUnknown
+	}
+
+	var permanent *PermanentError
+	if errors.As(err, &permanent) {
+		return ErrorPermanent
+	}
+	var transient *TransientError
+	if errors.As(err, &transient) {
+		return ErrorTransient
+	}
+
+	// Malformed payloads never decode on redelivery
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		return ErrorPermanent
+	}
+
+	var sqlErr mssql.Error
+	if errors.As(err, &sqlErr) {
+		switch {
+		case transientSQLErrors[sqlErr.SQLErrorNumber()]:
+			return ErrorTransient
+		case permanentSQLErrors[sqlErr.SQLErrorNumber()]:
+			return ErrorPermanent
+		}
+		return ErrorUnknown
+	}
+
+	var streamErr mssql.StreamError
+	if errors.As(err, &streamErr) {
+		return ErrorTransient
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ErrorTransient
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, driver.ErrBadConn),
+		errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.EPIPE):
+		return ErrorTransient
+	}
+
+	return ErrorUnknown
+}
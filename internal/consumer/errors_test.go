@@ -0,0 +1,42 @@
+package consumer_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"event-pipeline/internal/consumer"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+)
+
+func TestClassify(t *testing.T) {
+	var syntaxErr error
+	if err := json.Unmarshal([]byte(`{"eventId":`), &struct{}{}); err != nil {
+		syntaxErr = fmt.Errorf("failed to unmarshal UserCreated event: %w", err)
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want consumer.ErrorClass
+	}{
+		{"explicit permanent", consumer.Permanent(errors.New("bad")), consumer.ErrorPermanent},
+		{"explicit transient", consumer.Transient(errors.New("busy")), consumer.ErrorTransient},
+		{"malformed payload", syntaxErr, consumer.ErrorPermanent},
+		{"deadlock", fmt.Errorf("failed to upsert user: %w", mssql.Error{Number: 1205}), consumer.ErrorTransient},
+		{"unique violation", fmt.Errorf("failed to upsert user: %w", mssql.Error{Number: 2627}), consumer.ErrorPermanent},
+		{"handler timeout", fmt.Errorf("failed to upsert user: %w", context.DeadlineExceeded), consumer.ErrorTransient},
+		{"unclassified", errors.New("something else"), consumer.ErrorUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := consumer.Classify(tt.err); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,423 @@
+package consumer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"event-pipeline/internal/config"
+	"event-pipeline/internal/filelog"
+	"event-pipeline/internal/logger"
+
+	"github.com/sirupsen/logrus"
+)
+
+// fileScanInterval is how often the event directory is checked for new files
+const fileScanInterval = time.Second
+
+// fileTailInterval is how often files are checked for new lines while idle
+const fileTailInterval = 50 * time.Millisecond
+
+// FileSource tails the NDJSON files of a directory for offline development.
+// Every file is a partition and offsets are line numbers. Committed offsets
+// are kept in a checkpoint file per consumer group.
+type FileSource struct {
+	dir        string
+	checkpoint string
+
+	mu       sync.Mutex
+	topic    string
+	cb       RebalanceCallbacks
+	files    []*filePartition
+	byName   map[string]*filePartition
+	saved    map[string]fileCheckpointEntry
+	lastScan time.Time
+	next     int
+}
+
+// filePartition is a file being tailed
+type filePartition struct {
+	name    string
+	id      int32
+	file    *os.File
+	reader  *bufio.Reader
+	line    int64 // number of the next line to read
+	partial []byte
+	paused  bool
+}
+
+// fileCheckpointEntry is the committed position in one file
+type fileCheckpointEntry struct {
+	Partition int32 `json:"partition"`
+	Offset    int64 `json:"offset"`
+}
+
+// NewFileSource creates a source reading cfg.Dir on behalf of group
+func NewFileSource(cfg *config.FileConfig, group string) (*FileSource, error) {
+	checkpoints := filepath.Join(cfg.Dir, ".checkpoints")
+	if err := os.MkdirAll(checkpoints, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+
+	s := &FileSource{
+		dir:        cfg.Dir,
+		checkpoint: filepath.Join(checkpoints, group+".json"),
+		byName:     make(map[string]*filePartition),
+		saved:      make(map[string]fileCheckpointEntry),
+	}
+
+	data, err := os.ReadFile(s.checkpoint)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &s.saved); err != nil {
+			return nil, fmt.Errorf("failed to parse checkpoint %s: %w", s.checkpoint, err)
+		}
+	}
+	return s, nil
+}
+
+// Subscribe starts tailing the files currently in the directory; files
+// created later are assigned as they appear
+func (s *FileSource) Subscribe(topic string, cb RebalanceCallbacks) error {
+	s.mu.Lock()
+	s.topic = topic
+	s.cb = cb
+	s.mu.Unlock()
+
+	if err := s.scan(); err != nil {
+		return err
+	}
+
+	logger.Log.WithFields(logrus.Fields{
+		"dir":        s.dir,
+		"checkpoint": s.checkpoint,
+	}).Info("Successfully created file consumer")
+	return nil
+}
+
+// scan opens files added to the directory since the last scan, positioned
+// at their committed offset, and reports them as assigned
+func (s *FileSource) scan() error {
+	s.lastScan = time.Now()
+
+	names, err := filepath.Glob(filepath.Join(s.dir, "*"+filelog.Ext))
+	if err != nil {
+		return fmt.Errorf("failed to list event files: %w", err)
+	}
+	sort.Strings(names)
+
+	s.mu.Lock()
+	var added []TopicPartition
+	for _, path := range names {
+		name := filepath.Base(path)
+		if _, ok := s.byName[name]; ok {
+			continue
+		}
+
+		f, err := s.open(name)
+		if err != nil {
+			s.mu.Unlock()
+			return err
+		}
+		added = append(added, TopicPartition{Topic: s.topic, Partition: f.id, Offset: f.line})
+	}
+	cb := s.cb
+	s.mu.Unlock()
+
+	if len(added) == 0 {
+		return nil
+	}
+	if cb.Assigned != nil && cb.Assigned(added) {
+		s.Pause(added)
+	}
+	return nil
+}
+
+// open starts tailing a file at its committed offset
+func (s *FileSource) open(name string) (*filePartition, error) {
+	file, err := os.Open(filepath.Join(s.dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event file: %w", err)
+	}
+
+	// Files keep their partition across restarts through the checkpoint
+	saved, ok := s.saved[name]
+	if !ok {
+		saved.Partition = s.nextID()
+	}
+
+	f := &filePartition{name: name, id: saved.Partition, file: file, reader: bufio.NewReader(file)}
+	if err := f.skip(saved.Offset); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	s.files = append(s.files, f)
+	s.byName[name] = f
+	s.saved[name] = fileCheckpointEntry{Partition: f.id, Offset: f.line}
+	return f, nil
+}
+
+// nextID returns a partition ID not used by any file
+func (s *FileSource) nextID() int32 {
+	var id int32
+	for _, f := range s.files {
+		if f.id >= id {
+			id = f.id + 1
+		}
+	}
+	for _, saved := range s.saved {
+		if saved.Partition >= id {
+			id = saved.Partition + 1
+		}
+	}
+	return id
+}
+
+// partition returns the file of a partition
+func (s *FileSource) partition(id int32) (*filePartition, error) {
+	for _, f := range s.files {
+		if f.id == id {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown partition %d", id)
+}
+
+// skip advances past lines until the next line to read is line n, or the
+// end of the file is reached
+func (f *filePartition) skip(n int64) error {
+	for f.line < n {
+		data, err := f.reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			f.partial = append(f.partial, data...)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", f.name, err)
+		}
+		f.partial = nil
+		f.line++
+	}
+	return nil
+}
+
+// readLine returns the next complete line of the file, if any
+func (f *filePartition) readLine() ([]byte, int64, error) {
+	for {
+		data, err := f.reader.ReadBytes('\n')
+		f.partial = append(f.partial, data...)
+		if errors.Is(err, io.EOF) {
+			// Wait for the rest of a line still being written
+			return nil, 0, nil
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read %s: %w", f.name, err)
+		}
+
+		line := f.partial
+		f.partial = nil
+		offset := f.line
+		f.line++
+
+		if len(bytes.TrimSpace(line)) > 0 {
+			return line, offset, nil
+		}
+	}
+}
+
+// Read returns the next line from the unpaused files, taking one line from
+// each file in turn
+func (s *FileSource) Read(timeout time.Duration) (*Message, error) {
+	if time.Since(s.lastScan) >= fileScanInterval {
+		if err := s.scan(); err != nil {
+			return nil, err
+		}
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		msg, err := s.readNext()
+		if msg != nil || err != nil {
+			return msg, err
+		}
+
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return nil, nil
+		}
+		if wait > fileTailInterval {
+			wait = fileTailInterval
+		}
+		time.Sleep(wait)
+	}
+}
+
+// readNext reads one line from the next file that has one
+func (s *FileSource) readNext() (*Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 0; i < len(s.files); i++ {
+		f := s.files[(s.next+i)%len(s.files)]
+		if f.paused {
+			continue
+		}
+
+		line, offset, err := f.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if line == nil {
+			continue
+		}
+		s.next = (s.next + i + 1) % len(s.files)
+
+		msg := &Message{TopicPartition: TopicPartition{Topic: s.topic, Partition: f.id, Offset: offset}}
+		record, err := filelog.Decode(line)
+		if err != nil {
+			// Deliver the line as is so it is dead-lettered
+			msg.Value = bytes.TrimSpace(line)
+			return msg, nil
+		}
+		msg.Key = []byte(record.Key)
+		msg.Value = record.Value
+		msg.Timestamp = record.Timestamp
+		for k, v := range record.Headers {
+			msg.Headers = append(msg.Headers, Header{Key: k, Value: []byte(v)})
+		}
+		return msg, nil
+	}
+	return nil, nil
+}
+
+// Commit records offsets in the checkpoint file
+func (s *FileSource) Commit(offsets []TopicPartition) error {
+	s.mu.Lock()
+	for _, tp := range offsets {
+		if f, err := s.partition(tp.Partition); err == nil {
+			s.saved[f.name] = fileCheckpointEntry{Partition: f.id, Offset: tp.Offset}
+		}
+	}
+	data, err := json.MarshalIndent(s.saved, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+
+	// Replace the checkpoint atomically so a crash never leaves it torn
+	tmp := s.checkpoint + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, s.checkpoint); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Assignment returns every file being tailed
+func (s *FileSource) Assignment() ([]TopicPartition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	partitions := make([]TopicPartition, 0, len(s.files))
+	for _, f := range s.files {
+		partitions = append(partitions, TopicPartition{Topic: s.topic, Partition: f.id})
+	}
+	return partitions, nil
+}
+
+// Pause stops reading from files
+func (s *FileSource) Pause(partitions []TopicPartition) error {
+	return s.setPaused(partitions, true)
+}
+
+// Resume restarts reading from files
+func (s *FileSource) Resume(partitions []TopicPartition) error {
+	return s.setPaused(partitions, false)
+}
+
+func (s *FileSource) setPaused(partitions []TopicPartition, paused bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, tp := range partitions {
+		f, err := s.partition(tp.Partition)
+		if err != nil {
+			return err
+		}
+		f.paused = paused
+	}
+	return nil
+}
+
+// Seek rereads a file from the start up to line tp.Offset
+func (s *FileSource) Seek(tp TopicPartition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := s.partition(tp.Partition)
+	if err != nil {
+		return err
+	}
+	if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind %s: %w", f.name, err)
+	}
+	f.reader.Reset(f.file)
+	f.partial = nil
+	f.line = 0
+	return f.skip(tp.Offset)
+}
+
+// OffsetForTime returns the first line written at or after t, or the line
+// after the last one if there is none. Lines without a timestamp are skipped.
+func (s *FileSource) OffsetForTime(tp TopicPartition, t time.Time) (int64, error) {
+	s.mu.Lock()
+	f, err := s.partition(tp.Partition)
+	s.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	file, err := os.Open(f.file.Name())
+	if err != nil {
+		return 0, fmt.Errorf("failed to open %s: %w", f.name, err)
+	}
+	defer file.Close()
+
+	scan := &filePartition{name: f.name, file: file, reader: bufio.NewReader(file)}
+	for {
+		line, offset, err := scan.readLine()
+		if err != nil {
+			return 0, err
+		}
+		if line == nil {
+			return scan.line, nil
+		}
+		if record, err := filelog.Decode(line); err == nil && !record.Timestamp.Before(t) {
+			return offset, nil
+		}
+	}
+}
+
+// Close closes the files being tailed
+func (s *FileSource) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, f := range s.files {
+		f.file.Close()
+	}
+	return nil
+}
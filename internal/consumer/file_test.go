@@ -0,0 +1,107 @@
+package consumer_test
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"event-pipeline/internal/config"
+	"event-pipeline/internal/consumer"
+	"event-pipeline/internal/models"
+	"event-pipeline/internal/producer"
+)
+
+func TestFileSourceResumesFromCheckpoint(t *testing.T) {
+	cfg := &config.FileConfig{Dir: t.TempDir()}
+
+	prod, err := producer.NewFile(cfg, "events")
+	if err != nil {
+		t.Fatalf("Failed to create producer: %v", err)
+	}
+	for _, id := range []string{"user-1", "user-2", "user-3"} {
+		if err := prod.PublishUserCreated(models.UserCreated{
+			BaseEvent: models.BaseEvent{EventID: "evt-" + id, Timestamp: time.Now()},
+			UserID:    id,
+		}); err != nil {
+			t.Fatalf("Failed to publish: %v", err)
+		}
+	}
+	prod.Close()
+
+	// A hand-written bare event in another file is read as its own partition
+	bare := []byte(`{"eventId":"evt-4","eventType":"UserCreated","userId":"user-4"}` + "\n")
+	if err := os.WriteFile(filepath.Join(cfg.Dir, "fixtures.ndjson"), bare, 0o644); err != nil {
+		t.Fatalf("Failed to write fixture: %v", err)
+	}
+
+	source, err := consumer.NewFileSource(cfg, "group")
+	if err != nil {
+		t.Fatalf("Failed to create source: %v", err)
+	}
+	var assigned []consumer.TopicPartition
+	err = source.Subscribe("events", consumer.RebalanceCallbacks{
+		Assigned: func(partitions []consumer.TopicPartition) bool {
+			assigned = append(assigned, partitions...)
+			return false
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	if len(assigned) != 2 {
+		t.Fatalf("Expected 2 partitions, got %v", assigned)
+	}
+
+	read := make(map[string]*consumer.Message)
+	for i := 0; i < 4; i++ {
+		msg, err := source.Read(100 * time.Millisecond)
+		if err != nil || msg == nil {
+			t.Fatalf("Expected a message, got %v %v", msg, err)
+		}
+		read[string(msg.Key)+string(msg.Value)] = msg
+	}
+	if msg, _ := source.Read(10 * time.Millisecond); msg != nil {
+		t.Fatalf("Expected no more messages, got %s", msg.Value)
+	}
+
+	// Commit the first two lines of the producer's file
+	var events consumer.TopicPartition
+	for _, msg := range read {
+		if string(msg.Key) == "user-2" {
+			events = msg.TopicPartition
+		}
+	}
+	events.Offset++
+	if err := source.Commit([]consumer.TopicPartition{events}); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	source.Close()
+
+	source, err = consumer.NewFileSource(cfg, "group")
+	if err != nil {
+		t.Fatalf("Failed to reopen source: %v", err)
+	}
+	defer source.Close()
+	if err := source.Subscribe("events", consumer.RebalanceCallbacks{}); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	var keys []string
+	for {
+		msg, err := source.Read(10 * time.Millisecond)
+		if err != nil {
+			t.Fatalf("Failed to read: %v", err)
+		}
+		if msg == nil {
+			break
+		}
+		keys = append(keys, string(msg.Key))
+	}
+	// The fixture has no key
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "" || keys[1] != "user-3" {
+		t.Errorf("Expected user-3 and the uncommitted fixture after restart, got %q", keys)
+	}
+}
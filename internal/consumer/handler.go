@@ -0,0 +1,155 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"event-pipeline/internal/database"
+	"event-pipeline/internal/models"
+)
+
+// Handler processes a single event payload of a registered event type
+type Handler interface {
+	Handle(ctx context.Context, base models.BaseEvent, data []byte) error
+}
+
+// HandlerFunc adapts an ordinary function to the Handler interface
+type HandlerFunc func(ctx context.Context, base models.BaseEvent, data []byte) error
+
+// Handle calls f(ctx, base, data)
+func (f HandlerFunc) Handle(ctx context.Context, base models.BaseEvent, data []byte) error {
+	return f(ctx, base, data)
+}
+
+// Register sets the handler for an event type, replacing any existing one
+func (c *Consumer) Register(eventType models.EventType, handler Handler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers[eventType] = handler
+}
+
+// SetFallback sets the handler used for event types with no registered handler
+func (c *Consumer) SetFallback(handler Handler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fallback = handler
+}
+
+// CopyHandlers replaces the consumer's handlers and fallback with those of
+// another consumer, e.g. so retry tier consumers handle events like the main
+// consumer. Handlers registered on either consumer afterwards are not copied.
+func (c *Consumer) CopyHandlers(from *Consumer) {
+	from.mu.RLock()
+	handlers := make(map[models.EventType]Handler, len(from.handlers))
+	for eventType, h := range from.handlers {
+		handlers[eventType] = h
+	}
+	fallback := from.fallback
+	from.mu.RUnlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers = handlers
+	c.fallback = fallback
+}
+
+// handlerFor returns the handler registered for an event type, or the fallback
+func (c *Consumer) handlerFor(eventType models.EventType) Handler {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if h, ok := c.handlers[eventType]; ok {
+		return h
+	}
+	return c.fallback
+}
+
+// BatchHandler is a Handler whose database writes can be grouped with other
+// events into a single batch
+type BatchHandler interface {
+	Handler
+	AddToBatch(base models.BaseEvent, data []byte, batch *database.Batch) error
+}
+
+// ParentProvider is a Handler whose events write rows that other events
+// reference by foreign key
+type ParentProvider interface {
+	Handler
+	Provides(base models.BaseEvent, data []byte) (database.Parent, bool)
+}
+
+// projectionHandler decodes an event and applies it to a database projection,
+// either directly or as part of a batch
+type projectionHandler[E any] struct {
+	upsert   func(ctx context.Context, event E) error
+	add      func(batch *database.Batch, event E)
+	provides func(event E) database.Parent
+}
+
+// Handle decodes the event and upserts it
+func (h projectionHandler[E]) Handle(ctx context.Context, base models.BaseEvent, data []byte) error {
+	event, err := decodeEvent[E](base, data)
+	if err != nil {
+		return err
+	}
+	return h.upsert(ctx, event)
+}
+
+// AddToBatch decodes the event and appends it to batch
+func (h projectionHandler[E]) AddToBatch(base models.BaseEvent, data []byte, batch *database.Batch) error {
+	event, err := decodeEvent[E](base, data)
+	if err != nil {
+		return err
+	}
+	h.add(batch, event)
+	return nil
+}
+
+// Provides returns the parent row written by the event, if any
+func (h projectionHandler[E]) Provides(base models.BaseEvent, data []byte) (database.Parent, bool) {
+	if h.provides == nil {
+		return database.Parent{}, false
+	}
+	event, err := decodeEvent[E](base, data)
+	if err != nil {
+		return database.Parent{}, false
+	}
+	return h.provides(event), true
+}
+
+// decodeEvent unmarshals an event payload into its concrete type
+func decodeEvent[E any](base models.BaseEvent, data []byte) (E, error) {
+	var event E
+	if err := json.Unmarshal(data, &event); err != nil {
+		return event, fmt.Errorf("failed to unmarshal %s event: %w", base.EventType, err)
+	}
+	return event, nil
+}
+
+// registerDefaultHandlers registers the built-in database projections
+func (c *Consumer) registerDefaultHandlers() {
+	c.Register(models.UserCreatedEvent, projectionHandler[models.UserCreated]{
+		upsert:   c.db.UpsertUser,
+		add:      (*database.Batch).AddUser,
+		provides: func(e models.UserCreated) database.Parent { return database.UserParent(e.UserID) },
+	})
+	c.Register(models.OrderPlacedEvent, projectionHandler[models.OrderPlaced]{
+		upsert:   c.db.UpsertOrder,
+		add:      (*database.Batch).AddOrder,
+		provides: func(e models.OrderPlaced) database.Parent { return database.OrderParent(e.OrderID) },
+	})
+	c.Register(models.PaymentSettledEvent, projectionHandler[models.PaymentSettled]{
+		upsert: c.db.UpsertPayment,
+		add:    (*database.Batch).AddPayment,
+	})
+	c.Register(models.InventoryAdjustedEvent, projectionHandler[models.InventoryAdjusted]{
+		upsert: c.db.UpsertInventory,
+		add:    (*database.Batch).AddInventory,
+	})
+	c.SetFallback(HandlerFunc(unknownEventHandler))
+}
+
+// unknownEventHandler is the default fallback and rejects the event
+func unknownEventHandler(ctx context.Context, base models.BaseEvent, data []byte) error {
+	return Permanent(fmt.Errorf("unknown event type: %s", base.EventType))
+}
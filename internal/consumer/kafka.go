@@ -0,0 +1,235 @@
+package consumer
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"event-pipeline/internal/config"
+	"event-pipeline/internal/logger"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// KafkaSource is the default Source, reading a topic as a member of a
+// Kafka consumer group. A client that fails fatally is replaced by Reopen.
+type KafkaSource struct {
+	configMap *kafka.ConfigMap
+	topic     string
+	cb        RebalanceCallbacks
+
+	mu       sync.RWMutex
+	consumer *kafka.Consumer
+}
+
+// NewKafkaSource creates a Kafka source in the given consumer group.
+// Offsets are only committed explicitly.
+func NewKafkaSource(cfg *config.KafkaConfig, group string) (*KafkaSource, error) {
+	configMap := &kafka.ConfigMap{
+		"bootstrap.servers":  cfg.Brokers,
+		"group.id":           group,
+		"auto.offset.reset":  "earliest",
+		"enable.auto.commit": false,
+	}
+	if cfg.AssignmentStrategy != "" {
+		configMap.SetKey("partition.assignment.strategy", cfg.AssignmentStrategy)
+	}
+
+	c, err := kafka.NewConsumer(configMap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer: %w", err)
+	}
+	return &KafkaSource{configMap: configMap, consumer: c}, nil
+}
+
+// client returns the current Kafka client
+func (s *KafkaSource) client() *kafka.Consumer {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.consumer
+}
+
+// Subscribe subscribes to topic with cb as the rebalance listener
+func (s *KafkaSource) Subscribe(topic string, cb RebalanceCallbacks) error {
+	s.topic = topic
+	s.cb = cb
+	return s.client().Subscribe(topic, s.rebalance)
+}
+
+// Reopen closes the current client and subscribes a new one in the same
+// group. The consumer must already treat the old client's partitions as lost.
+func (s *KafkaSource) Reopen() error {
+	c, err := kafka.NewConsumer(s.configMap)
+	if err != nil {
+		return fmt.Errorf("failed to create consumer: %w", err)
+	}
+
+	s.mu.Lock()
+	old := s.consumer
+	s.consumer = c
+	s.mu.Unlock()
+
+	// Rebalance events from the old client are ignored from here on
+	if err := old.Close(); err != nil {
+		logger.Log.Warnf("Failed to close failed consumer: %v", err)
+	}
+
+	if err := c.Subscribe(s.topic, s.rebalance); err != nil {
+		return fmt.Errorf("failed to subscribe: %w", err)
+	}
+	return nil
+}
+
+// rebalance is the Kafka rebalance callback. The client performs the
+// (incremental) assign or unassign after it returns.
+func (s *KafkaSource) rebalance(kc *kafka.Consumer, ev kafka.Event) error {
+	if kc != s.client() {
+		// A client replaced by Reopen is giving up its partitions
+		return nil
+	}
+
+	switch e := ev.(type) {
+	case kafka.AssignedPartitions:
+		if s.cb.Assigned != nil && s.cb.Assigned(fromKafka(e.Partitions)) {
+			assignPaused(kc, e.Partitions)
+		}
+	case kafka.RevokedPartitions:
+		if s.cb.Revoked != nil {
+			s.cb.Revoked(fromKafka(e.Partitions), kc.AssignmentLost())
+		}
+	}
+	return nil
+}
+
+// assignPaused assigns partitions and pauses them before any are fetched
+func assignPaused(kc *kafka.Consumer, partitions []kafka.TopicPartition) {
+	var err error
+	if kc.GetRebalanceProtocol() == "COOPERATIVE" {
+		err = kc.IncrementalAssign(partitions)
+	} else {
+		err = kc.Assign(partitions)
+	}
+	if err != nil {
+		logger.Log.Errorf("Failed to assign partitions: %v", err)
+		return
+	}
+	if err := kc.Pause(partitions); err != nil {
+		logger.Log.Errorf("Failed to pause assigned partitions: %v", err)
+	}
+}
+
+// Read polls for the next message. Errors the client cannot recover from
+// are returned as a *FatalError.
+func (s *KafkaSource) Read(timeout time.Duration) (*Message, error) {
+	msg, err := s.client().ReadMessage(timeout)
+	if err != nil {
+		var kafkaErr kafka.Error
+		if errors.As(err, &kafkaErr) && kafkaErr.Code() == kafka.ErrTimedOut {
+			return nil, nil
+		}
+		if errors.As(err, &kafkaErr) && kafkaErr.IsFatal() {
+			return nil, Fatal(err)
+		}
+		return nil, err
+	}
+
+	headers := make([]Header, 0, len(msg.Headers))
+	for _, h := range msg.Headers {
+		headers = append(headers, Header{Key: h.Key, Value: h.Value})
+	}
+	return &Message{
+		TopicPartition: fromKafkaPartition(msg.TopicPartition),
+		Key:            msg.Key,
+		Value:          msg.Value,
+		Headers:        headers,
+		Timestamp:      msg.Timestamp,
+	}, nil
+}
+
+// Commit commits offsets to the consumer group
+func (s *KafkaSource) Commit(offsets []TopicPartition) error {
+	_, err := s.client().CommitOffsets(toKafka(offsets))
+	return err
+}
+
+// Assignment returns the partitions currently assigned
+func (s *KafkaSource) Assignment() ([]TopicPartition, error) {
+	partitions, err := s.client().Assignment()
+	if err != nil {
+		return nil, err
+	}
+	return fromKafka(partitions), nil
+}
+
+// Pause stops fetching from partitions
+func (s *KafkaSource) Pause(partitions []TopicPartition) error {
+	return s.client().Pause(toKafka(partitions))
+}
+
+// Resume restarts fetching from partitions
+func (s *KafkaSource) Resume(partitions []TopicPartition) error {
+	return s.client().Resume(toKafka(partitions))
+}
+
+// Seek moves a partition to tp.Offset
+func (s *KafkaSource) Seek(tp TopicPartition) error {
+	return s.client().Seek(toKafkaPartition(tp), 0)
+}
+
+// OffsetForTime looks up the first offset at or after t
+func (s *KafkaSource) OffsetForTime(tp TopicPartition, t time.Time) (int64, error) {
+	tp.Offset = t.UnixMilli()
+	offsets, err := s.client().OffsetsForTimes([]kafka.TopicPartition{toKafkaPartition(tp)}, int(adminTimeout.Milliseconds()))
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up offset for time: %w", err)
+	}
+	if len(offsets) != 1 || offsets[0].Error != nil {
+		return 0, fmt.Errorf("failed to look up offset for time: %v", offsets)
+	}
+
+	offset := int64(offsets[0].Offset)
+	if offset < 0 {
+		// No message at or after t
+		_, high, err := s.client().QueryWatermarkOffsets(tp.Topic, tp.Partition, int(adminTimeout.Milliseconds()))
+		if err != nil {
+			return 0, fmt.Errorf("failed to query partition end: %w", err)
+		}
+		offset = high
+	}
+	return offset, nil
+}
+
+// Close leaves the consumer group and closes the client
+func (s *KafkaSource) Close() error {
+	return s.client().Close()
+}
+
+func fromKafkaPartition(tp kafka.TopicPartition) TopicPartition {
+	topic := ""
+	if tp.Topic != nil {
+		topic = *tp.Topic
+	}
+	return TopicPartition{Topic: topic, Partition: tp.Partition, Offset: int64(tp.Offset)}
+}
+
+func toKafkaPartition(tp TopicPartition) kafka.TopicPartition {
+	topic := tp.Topic
+	return kafka.TopicPartition{Topic: &topic, Partition: tp.Partition, Offset: kafka.Offset(tp.Offset)}
+}
+
+func fromKafka(partitions []kafka.TopicPartition) []TopicPartition {
+	out := make([]TopicPartition, 0, len(partitions))
+	for _, tp := range partitions {
+		out = append(out, fromKafkaPartition(tp))
+	}
+	return out
+}
+
+func toKafka(partitions []TopicPartition) []kafka.TopicPartition {
+	out := make([]kafka.TopicPartition, 0, len(partitions))
+	for _, tp := range partitions {
+		out = append(out, toKafkaPartition(tp))
+	}
+	return out
+}
@@ -0,0 +1,142 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"event-pipeline/internal/logger"
+	"event-pipeline/internal/metrics"
+	"event-pipeline/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Middleware wraps a Handler with behaviour shared by every event type
+type Middleware func(next Handler) Handler
+
+// Chain composes middleware into one. The first middleware is the outermost,
+// so it sees each event first and each result last.
+func Chain(middleware ...Middleware) Middleware {
+	return func(next Handler) Handler {
+		for i := len(middleware) - 1; i >= 0; i-- {
+			next = middleware[i](next)
+		}
+		return next
+	}
+}
+
+// Use adds middleware around every handler, including the fallback. Each
+// attempt of the retry policy runs through the whole chain, and so do
+// events written through database batches. Validate always runs innermost,
+// so invalid events never reach a handler.
+func (c *Consumer) Use(middleware ...Middleware) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.middleware = append(c.middleware, middleware...)
+}
+
+// wrap applies the consumer's middleware to a handler
+func (c *Consumer) wrap(handler Handler) Handler {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return Chain(c.middleware...)(Validate()(handler))
+}
+
+// PanicError is the error Recover returns for a handler that panicked
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string { return fmt.Sprintf("handler panicked: %v", e.Value) }
+
+// Recover turns a handler panic into a permanent error, so the event is
+// dead-lettered instead of crashing the consumer
+func Recover() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, base models.BaseEvent, data []byte) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					stack := debug.Stack()
+					logger.WithEventID(base.EventID).WithFields(logrus.Fields{
+						"eventType": base.EventType,
+						"stack":     string(stack),
+					}).Errorf("Handler panicked: %v", r)
+					metrics.HandlerPanics.WithLabelValues(string(base.EventType)).Inc()
+					err = Permanent(&PanicError{Value: r, Stack: stack})
+				}
+			}()
+			return next.Handle(ctx, base, data)
+		})
+	}
+}
+
+// Logging logs every handler call with its duration at debug level
+func Logging() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, base models.BaseEvent, data []byte) error {
+			start := time.Now()
+			err := next.Handle(ctx, base, data)
+
+			entry := logger.WithEventID(base.EventID).WithFields(logrus.Fields{
+				"eventType": base.EventType,
+				"duration":  time.Since(start).String(),
+			})
+			if span, ok := SpanFromContext(ctx); ok {
+				entry = entry.WithField("traceId", span.TraceID)
+			}
+			if err != nil {
+				entry.WithField("error", err.Error()).Debug("Handler failed")
+			} else {
+				entry.Debug("Event handled")
+			}
+			return err
+		})
+	}
+}
+
+// Validate rejects events that fail their type's validation rules with a
+// permanent *models.ValidationError, which the consumer dead-letters along
+// with the invalid fields. Events of unknown types are passed on unchecked.
+func Validate() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, base models.BaseEvent, data []byte) error {
+			if err := models.Validate(base.EventType, data); err != nil {
+				return Permanent(err)
+			}
+			return next.Handle(ctx, base, data)
+		})
+	}
+}
+
+// Metrics records the duration and outcome of every handler call
+func Metrics() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, base models.BaseEvent, data []byte) error {
+			start := time.Now()
+			err := next.Handle(ctx, base, data)
+
+			status := "success"
+			if err != nil {
+				status = Classify(err).String()
+			}
+			metrics.HandlerDuration.WithLabelValues(string(base.EventType), status).Observe(time.Since(start).Seconds())
+			metrics.KafkaConsumeLatency.Observe(time.Since(start).Seconds())
+			return err
+		})
+	}
+}
+
+// Timeout bounds every handler call to d, so each attempt of the retry
+// policy gets d afresh
+func Timeout(d time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, base models.BaseEvent, data []byte) error {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			return next.Handle(ctx, base, data)
+		})
+	}
+}
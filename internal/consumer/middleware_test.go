@@ -0,0 +1,129 @@
+package consumer_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"event-pipeline/internal/config"
+	"event-pipeline/internal/consumer"
+	"event-pipeline/internal/models"
+)
+
+func TestChainOrder(t *testing.T) {
+	var calls []string
+	trace := func(name string) consumer.Middleware {
+		return func(next consumer.Handler) consumer.Handler {
+			return consumer.HandlerFunc(func(ctx context.Context, base models.BaseEvent, data []byte) error {
+				calls = append(calls, name+" in")
+				err := next.Handle(ctx, base, data)
+				calls = append(calls, name+" out")
+				return err
+			})
+		}
+	}
+
+	handler := consumer.Chain(trace("a"), trace("b"))(consumer.HandlerFunc(
+		func(ctx context.Context, base models.BaseEvent, data []byte) error {
+			calls = append(calls, "handler")
+			return nil
+		}))
+	if err := handler.Handle(context.Background(), models.BaseEvent{}, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := "a in,b in,handler,b out,a out"
+	if got := strings.Join(calls, ","); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
+
+func TestRecoverDeadLettersPanics(t *testing.T) {
+	handler := consumer.Recover()(consumer.HandlerFunc(
+		func(ctx context.Context, base models.BaseEvent, data []byte) error {
+			panic("boom")
+		}))
+
+	err := handler.Handle(context.Background(), models.BaseEvent{EventID: "evt-1"}, nil)
+	var panicErr *consumer.PanicError
+	if !errors.As(err, &panicErr) || panicErr.Value != "boom" {
+		t.Fatalf("Expected a panic error, got %v", err)
+	}
+	if consumer.Classify(err) != consumer.ErrorPermanent {
+		t.Errorf("Expected a permanent error, got %s", consumer.Classify(err))
+	}
+
+	// A consumer using Recover keeps running and dead-letters the event
+	source := newMemorySource([]byte(`{"eventId":"evt-1","eventType":"Exploding"}`))
+	dlqClient := &memoryDLQ{}
+	cfg := &config.KafkaConfig{Workers: 1, BatchSize: 1, RetryMaxAttempts: 3}
+	c, err := consumer.NewWithSource(cfg, source, "events", &memoryStore{}, dlqClient)
+	if err != nil {
+		t.Fatalf("Failed to create consumer: %v", err)
+	}
+	defer c.Stop()
+
+	c.Register("Exploding", consumer.HandlerFunc(func(ctx context.Context, base models.BaseEvent, data []byte) error {
+		panic("boom")
+	}))
+	c.Use(consumer.Recover())
+	go c.Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for dlqClient.count() == 0 && ctx.Err() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if report := c.Drain(ctx); !report.Completed {
+		t.Fatalf("Expected drain to complete, got %+v", report)
+	}
+
+	if dlqClient.count() != 1 || !strings.Contains(dlqClient.entries[0].Error, "boom") {
+		t.Errorf("Expected the panic to be dead-lettered, got %+v", dlqClient.entries)
+	}
+	if source.committed[0] != 1 {
+		t.Errorf("Expected commit at offset 1, got %d", source.committed[0])
+	}
+}
+
+func TestValidateRejectsInvalidEvents(t *testing.T) {
+	var handled []string
+	handler := consumer.Validate()(consumer.HandlerFunc(
+		func(ctx context.Context, base models.BaseEvent, data []byte) error {
+			handled = append(handled, base.EventID)
+			return nil
+		}))
+
+	invalid := []byte(`{"eventId":"evt-1","eventType":"UserCreated","email":"nope"}`)
+	err := handler.Handle(context.Background(), models.BaseEvent{EventID: "evt-1", EventType: models.UserCreatedEvent}, invalid)
+	var verr *models.ValidationError
+	if !errors.As(err, &verr) || consumer.Classify(err) != consumer.ErrorPermanent {
+		t.Errorf("Expected a permanent validation error, got %v", err)
+	}
+
+	unknown := []byte(`{"eventId":"evt-2","eventType":"Custom"}`)
+	if err := handler.Handle(context.Background(), models.BaseEvent{EventID: "evt-2", EventType: "Custom"}, unknown); err != nil {
+		t.Errorf("Expected events of unknown types to pass, got %v", err)
+	}
+	if strings.Join(handled, ",") != "evt-2" {
+		t.Errorf("Expected only evt-2 to be handled, got %v", handled)
+	}
+}
+
+func TestTimeoutBoundsEachCall(t *testing.T) {
+	handler := consumer.Timeout(20 * time.Millisecond)(consumer.HandlerFunc(
+		func(ctx context.Context, base models.BaseEvent, data []byte) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}))
+
+	for i := 0; i < 2; i++ {
+		start := time.Now()
+		err := handler.Handle(context.Background(), models.BaseEvent{}, nil)
+		if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > time.Second {
+			t.Errorf("Expected call %d to time out, got %v after %s", i, err, time.Since(start))
+		}
+	}
+}
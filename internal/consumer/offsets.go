@@ -0,0 +1,214 @@
+package consumer
+
+import "sync"
+
+// partitionKey identifies a topic partition
+type partitionKey struct {
+	topic     string
+	partition int32
+}
+
+func keyOf(tp TopicPartition) partitionKey {
+	return partitionKey{topic: tp.Topic, partition: tp.Partition}
+}
+
+// partitionOffsets tracks in-flight offsets for a single partition
+type partitionOffsets struct {
+	pending   map[int64]uint64 // dispatched but not yet finished, with their epoch
+	next      int64            // offset after the highest dispatched message
+	committed int64            // last offset committed to the source, -1 if none
+	epoch     uint64           // incremented every time the partition is rewound
+	resumeAt  int64            // offset expected after a rewind, -1 if none
+}
+
+// offsetTracker computes safe commit positions while messages of the same
+// partition complete out of order on different workers
+type offsetTracker struct {
+	mu    sync.Mutex
+	parts map[partitionKey]*partitionOffsets
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{parts: make(map[partitionKey]*partitionOffsets)}
+}
+
+func (t *offsetTracker) partition(key partitionKey) *partitionOffsets {
+	p, ok := t.parts[key]
+	if !ok {
+		p = &partitionOffsets{
+			pending:   make(map[int64]uint64),
+			committed: -1,
+			resumeAt:  -1,
+		}
+		t.parts[key] = p
+	}
+	return p
+}
+
+// dispatched records a message handed to a worker. It returns the partition
+// epoch for the message, or false if the message was fetched before a rewind
+// took effect and must be dropped.
+func (t *offsetTracker) dispatched(key partitionKey, offset int64) (uint64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p := t.partition(key)
+	if p.resumeAt >= 0 {
+		if offset != p.resumeAt {
+			return 0, false
+		}
+		p.resumeAt = -1
+	}
+
+	p.pending[offset] = p.epoch
+	if offset >= p.next {
+		p.next = offset + 1
+	}
+	return p.epoch, true
+}
+
+// done marks a message as fully processed
+func (t *offsetTracker) done(key partitionKey, offset int64, epoch uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p := t.partition(key)
+	if e, ok := p.pending[offset]; ok && e == epoch {
+		delete(p.pending, offset)
+	}
+}
+
+// current reports whether a message dispatched in epoch should still be
+// processed, i.e. it has not been discarded by a rewind or revocation
+func (t *offsetTracker) current(key partitionKey, offset int64, epoch uint64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.partition(key).pending[offset]
+	return ok && e == epoch
+}
+
+// rewind discards in-flight work at or after offset so the partition can be
+// redelivered from that point. It returns false, changing nothing, if a
+// rewind to an earlier offset is already awaiting redelivery.
+func (t *offsetTracker) rewind(key partitionKey, offset int64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p := t.partition(key)
+	if p.resumeAt >= 0 && offset >= p.resumeAt {
+		return false
+	}
+	p.epoch++
+	for o := range p.pending {
+		if o >= offset {
+			delete(p.pending, o)
+		}
+	}
+	p.next = offset
+	p.resumeAt = offset
+	return true
+}
+
+// seek repositions a partition at offset on operator request. All in-flight
+// work is discarded, and the new position becomes committable even if it is
+// behind the last commit.
+func (t *offsetTracker) seek(key partitionKey, offset int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p := t.partition(key)
+	p.epoch++
+	p.pending = make(map[int64]uint64)
+	p.next = offset
+	p.committed = -1
+	p.resumeAt = -1
+}
+
+// committable returns, per partition, the offset up to which every message
+// has been processed, skipping partitions whose position has not moved
+func (t *offsetTracker) committable() []TopicPartition {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var offsets []TopicPartition
+	for key, p := range t.parts {
+		pos := p.next
+		for o := range p.pending {
+			if o < pos {
+				pos = o
+			}
+		}
+		if pos <= p.committed || pos == 0 {
+			continue
+		}
+
+		offsets = append(offsets, TopicPartition{
+			Topic:     key.topic,
+			Partition: key.partition,
+			Offset:    pos,
+		})
+	}
+	return offsets
+}
+
+// markCommitted records offsets successfully committed to the source
+func (t *offsetTracker) markCommitted(offsets []TopicPartition) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, tp := range offsets {
+		p := t.partition(keyOf(tp))
+		if tp.Offset > p.committed {
+			p.committed = tp.Offset
+		}
+	}
+}
+
+// backlog reports the in-flight message count and committed offset of every
+// tracked partition
+func (t *offsetTracker) backlog() []PartitionBacklog {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	backlog := make([]PartitionBacklog, 0, len(t.parts))
+	for key, p := range t.parts {
+		backlog = append(backlog, PartitionBacklog{
+			Topic:     key.topic,
+			Partition: key.partition,
+			Pending:   len(p.pending),
+			Committed: p.committed,
+		})
+	}
+	return backlog
+}
+
+// pendingIn returns the number of in-flight messages across the given partitions
+func (t *offsetTracker) pendingIn(keys []partitionKey) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := 0
+	for _, key := range keys {
+		if p, ok := t.parts[key]; ok {
+			n += len(p.pending)
+		}
+	}
+	return n
+}
+
+// revoke forgets the state of partitions handed to another consumer. The
+// epoch is bumped so queued work for them is skipped.
+func (t *offsetTracker) revoke(keys []partitionKey) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, key := range keys {
+		p := t.partition(key)
+		p.epoch++
+		p.pending = make(map[int64]uint64)
+		p.next = 0
+		p.committed = -1
+		p.resumeAt = -1
+	}
+}
@@ -0,0 +1,94 @@
+package consumer
+
+import "testing"
+
+func TestOffsetTrackerCommitsLowestUnfinished(t *testing.T) {
+	tracker := newOffsetTracker()
+	key := partitionKey{topic: "events", partition: 0}
+
+	epochs := make(map[int64]uint64)
+	for offset := int64(0); offset < 4; offset++ {
+		epoch, ok := tracker.dispatched(key, offset)
+		if !ok {
+			t.Fatalf("Expected offset %d to be dispatched", offset)
+		}
+		epochs[offset] = epoch
+	}
+
+	// Offsets 1 and 3 finish first; offset 0 is still in flight
+	tracker.done(key, 1, epochs[1])
+	tracker.done(key, 3, epochs[3])
+	if offsets := tracker.committable(); len(offsets) != 0 {
+		t.Fatalf("Expected nothing committable, got %v", offsets)
+	}
+
+	tracker.done(key, 0, epochs[0])
+	offsets := tracker.committable()
+	if len(offsets) != 1 || offsets[0].Offset != 2 {
+		t.Fatalf("Expected commit at offset 2, got %v", offsets)
+	}
+	tracker.markCommitted(offsets)
+
+	tracker.done(key, 2, epochs[2])
+	offsets = tracker.committable()
+	if len(offsets) != 1 || offsets[0].Offset != 4 {
+		t.Fatalf("Expected commit at offset 4, got %v", offsets)
+	}
+}
+
+func TestOffsetTrackerRewind(t *testing.T) {
+	tracker := newOffsetTracker()
+	key := partitionKey{topic: "events", partition: 0}
+
+	for offset := int64(0); offset < 3; offset++ {
+		tracker.dispatched(key, offset)
+	}
+	tracker.rewind(key, 1)
+
+	if !tracker.current(key, 0, 0) {
+		t.Error("Expected work before the rewound offset to stay current")
+	}
+	if tracker.current(key, 2, 0) {
+		t.Error("Expected work after the rewound offset to be stale")
+	}
+	if tracker.rewind(key, 2) {
+		t.Error("Expected a later rewind to be ignored while one is pending")
+	}
+	tracker.done(key, 0, 0)
+
+	// Messages fetched before the seek took effect are dropped
+	if _, ok := tracker.dispatched(key, 2); ok {
+		t.Error("Expected offset 2 to be dropped before the rewound offset is redelivered")
+	}
+
+	epoch, ok := tracker.dispatched(key, 1)
+	if !ok || epoch != 1 {
+		t.Fatalf("Expected offset 1 to be redelivered in epoch 1, got %d %v", epoch, ok)
+	}
+
+	offsets := tracker.committable()
+	if len(offsets) != 1 || offsets[0].Offset != 1 {
+		t.Fatalf("Expected commit at offset 1, got %v", offsets)
+	}
+}
+
+func TestOffsetTrackerSeek(t *testing.T) {
+	tracker := newOffsetTracker()
+	key := partitionKey{topic: "events", partition: 0}
+
+	for offset := int64(10); offset < 13; offset++ {
+		tracker.dispatched(key, offset)
+	}
+	tracker.done(key, 10, 0)
+	tracker.markCommitted(tracker.committable())
+
+	// Seeking back discards in-flight work and commits the earlier position
+	tracker.seek(key, 5)
+	if tracker.current(key, 11, 0) {
+		t.Error("Expected in-flight work to be discarded by a seek")
+	}
+	offsets := tracker.committable()
+	if len(offsets) != 1 || offsets[0].Offset != 5 {
+		t.Fatalf("Expected commit at offset 5, got %v", offsets)
+	}
+}
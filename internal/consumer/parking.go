@@ -0,0 +1,253 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"event-pipeline/internal/database"
+	"event-pipeline/internal/logger"
+	"event-pipeline/internal/metrics"
+	"event-pipeline/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// parkingSweepInterval is how often parked events are checked for expiry,
+// unless the parking timeout is shorter
+const parkingSweepInterval = 10 * time.Second
+
+// ParkingStore holds events waiting for a parent row, keyed by the parent
+type ParkingStore interface {
+	Park(ctx context.Context, entry models.ParkedEvent) error
+	Take(ctx context.Context, parent string) ([]models.ParkedEvent, error)
+	Expired(ctx context.Context, cutoff time.Time) ([]string, error)
+}
+
+// SetParking parks events whose parent row is missing until the parent is
+// written, dead-lettering them once timeout has elapsed
+func (c *Consumer) SetParking(store ParkingStore, timeout time.Duration) {
+	c.parking = store
+	c.parkTimeout = timeout
+}
+
+// park stores an event under the parent it is missing. It returns false if
+// parking is disabled or the event could not be stored.
+func (c *Consumer) park(msg *Message, base models.BaseEvent, missing *database.MissingParentError) bool {
+	parked := c.repark(base, models.ParkedEvent{
+		EventID:      base.EventID,
+		Parent:       missing.Parent.String(),
+		OriginalData: string(msg.Value),
+		Key:          string(msg.Key),
+		Partition:    msg.TopicPartition.Partition,
+		Error:        missing.Error(),
+		ParkedAt:     time.Now(),
+	})
+	if parked {
+		metrics.ParkedEvents.WithLabelValues(string(base.EventType), "parked").Inc()
+	}
+	return parked
+}
+
+// repark stores a parked event entry, keeping its original parking time
+func (c *Consumer) repark(base models.BaseEvent, entry models.ParkedEvent) bool {
+	if c.parking == nil {
+		return false
+	}
+
+	// Parked events must not be lost to a shutdown in progress
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := c.parking.Park(ctx, entry); err != nil {
+		logger.WithEventID(base.EventID).Errorf("Failed to park event: %v", err)
+		return false
+	}
+	return true
+}
+
+// keepParked parks a taken event again for the next run
+func (c *Consumer) keepParked(entry models.ParkedEvent) {
+	c.repark(models.BaseEvent{EventID: entry.EventID}, entry)
+}
+
+// replayChildren replays the events parked on the row written by an event
+func (c *Consumer) replayChildren(base models.BaseEvent, data []byte) {
+	if c.parking == nil {
+		return
+	}
+	provider, ok := c.handlerFor(base.EventType).(ParentProvider)
+	if !ok {
+		return
+	}
+	parent, ok := provider.Provides(base, data)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, 5*time.Second)
+	entries, err := c.parking.Take(ctx, parent.String())
+	cancel()
+	if err != nil {
+		logger.WithEventID(base.EventID).Errorf("Failed to take parked events for %s: %v", parent, err)
+		return
+	}
+
+	for _, entry := range entries {
+		c.queueReplay(entry, false)
+	}
+}
+
+// queueReplay hands a parked event to the poll loop, which routes it to the
+// worker owning its key like any message, so that it is replayed in order
+// with the other messages of its key. Once polling has stopped, the event is
+// parked again for the next run.
+func (c *Consumer) queueReplay(entry models.ParkedEvent, expired bool) {
+	c.replayMu.Lock()
+	if c.replayClosed {
+		c.replayMu.Unlock()
+		c.keepParked(entry)
+		return
+	}
+	c.replays = append(c.replays, work{parked: &entry, expired: expired})
+	c.replayMu.Unlock()
+
+	select {
+	case c.replayReady <- struct{}{}:
+	default:
+	}
+}
+
+// routeReplays dispatches the queued replays on the poll goroutine
+func (c *Consumer) routeReplays() {
+	c.replayMu.Lock()
+	replays := c.replays
+	c.replays = nil
+	c.replayMu.Unlock()
+
+	for i, w := range replays {
+		if !c.enqueue([]byte(w.parked.Key), w.parked.Partition, w) {
+			for _, w := range replays[i:] {
+				c.keepParked(*w.parked)
+			}
+			return
+		}
+	}
+}
+
+// openReplays accepts replays once the poll loop starts
+func (c *Consumer) openReplays() {
+	c.replayMu.Lock()
+	defer c.replayMu.Unlock()
+	c.replayClosed = false
+}
+
+// closeReplays stops accepting replays when the poll loop exits, parking
+// again those it has not routed
+func (c *Consumer) closeReplays() {
+	c.replayMu.Lock()
+	replays := c.replays
+	c.replays, c.replayClosed = nil, true
+	c.replayMu.Unlock()
+
+	for _, w := range replays {
+		c.keepParked(*w.parked)
+	}
+}
+
+// replay processes a parked event whose parent may now exist. An event that
+// is still missing its parent is parked again unless it has expired.
+// Successful events replay their own parked children in turn.
+func (c *Consumer) replay(entry models.ParkedEvent, expired bool) {
+	if c.ctx.Err() != nil {
+		c.keepParked(entry)
+		return
+	}
+	data := []byte(entry.OriginalData)
+
+	var base models.BaseEvent
+	if err := json.Unmarshal(data, &base); err != nil {
+		c.sendToDLQ(entry.EventID, entry.OriginalData, fmt.Sprintf("Failed to parse parked event: %v", err), 0)
+		return
+	}
+
+	attempts, err := c.handleWithRetry(c.ctx, c.handlerFor(base.EventType), base, data)
+	if err == nil {
+		metrics.ParkedEvents.WithLabelValues(string(base.EventType), "replayed").Inc()
+		logger.WithEventID(base.EventID).WithField("parent", entry.Parent).Info("Parked event replayed")
+		c.replayChildren(base, data)
+		return
+	}
+
+	if c.ctx.Err() != nil && c.repark(base, entry) {
+		// Shutting down: keep the event parked for the next run
+		return
+	}
+	var missing *database.MissingParentError
+	if !expired && errors.As(err, &missing) {
+		entry.Parent = missing.Parent.String()
+		entry.Error = missing.Error()
+		if c.repark(base, entry) {
+			return
+		}
+	}
+
+	if expired {
+		metrics.ParkedEvents.WithLabelValues(string(base.EventType), "expired").Inc()
+	}
+	logger.WithEventID(base.EventID).WithFields(logrus.Fields{
+		"parent":   entry.Parent,
+		"attempts": attempts,
+		"expired":  expired,
+	}).Errorf("Failed to replay parked event: %v", err)
+	c.sendToDLQ(base.EventID, entry.OriginalData, err.Error(), attempts)
+}
+
+// sweepParked replays events parked for longer than the parking timeout one
+// last time, dead-lettering those whose parent is still missing. A parent's
+// events expire together, once the first of them is due.
+func (c *Consumer) sweepParked() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(min(parkingSweepInterval, c.parkTimeout))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.pollCtx.Done():
+			return
+		case <-ticker.C:
+			c.expireParked()
+		}
+	}
+}
+
+// expireParked replays every parent's events whose timeout has elapsed
+func (c *Consumer) expireParked() {
+	ctx, cancel := context.WithTimeout(c.ctx, 5*time.Second)
+	parents, err := c.parking.Expired(ctx, time.Now().Add(-c.parkTimeout))
+	cancel()
+	if err != nil {
+		logger.Log.Errorf("Failed to check parked events: %v", err)
+		return
+	}
+
+	for _, parent := range parents {
+		if c.pollCtx.Err() != nil {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.ctx, 5*time.Second)
+		entries, err := c.parking.Take(ctx, parent)
+		cancel()
+		if err != nil {
+			logger.Log.Errorf("Failed to take parked events for %s: %v", parent, err)
+			continue
+		}
+		for _, entry := range entries {
+			c.queueReplay(entry, true)
+		}
+	}
+}
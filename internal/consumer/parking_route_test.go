@@ -0,0 +1,84 @@
+package consumer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"event-pipeline/internal/models"
+)
+
+func TestReplaysFollowTheMessagesOfTheirKey(t *testing.T) {
+	c := &Consumer{
+		ctx:         context.Background(),
+		pollCtx:     context.Background(),
+		offsets:     newOffsetTracker(),
+		replayReady: make(chan struct{}, 1),
+	}
+	for i := 0; i < 4; i++ {
+		c.queues = append(c.queues, make(chan work, 10))
+	}
+
+	entries := []models.ParkedEvent{
+		{EventID: "evt-1", Key: "order-1", Partition: 0},
+		{EventID: "evt-2", Key: "order-2", Partition: 0},
+		// Keyless events are routed by partition, as their messages were
+		{EventID: "evt-3", Partition: 3},
+	}
+	for i, entry := range entries {
+		c.dispatch(&Message{
+			TopicPartition: TopicPartition{Topic: "events", Partition: entry.Partition, Offset: int64(i)},
+			Key:            []byte(entry.Key),
+		})
+		c.queueReplay(entry, false)
+		c.routeReplays()
+
+		// The replay lands behind the message of its key, on the same worker
+		for _, queue := range c.queues {
+			if len(queue) == 0 {
+				continue
+			}
+			if msg := <-queue; msg.msg == nil || msg.msg.TopicPartition.Offset != int64(i) {
+				t.Fatalf("%s: expected the message of its key first, got %+v", entry.EventID, msg)
+			}
+			if replay := <-queue; replay.parked == nil || replay.parked.EventID != entry.EventID {
+				t.Errorf("%s: expected its replay on the worker owning its key, got %+v", entry.EventID, replay)
+			}
+		}
+	}
+}
+
+func TestReplaysAreKeptParkedOncePollingStops(t *testing.T) {
+	store := &recordingParking{}
+	c := &Consumer{
+		ctx:         context.Background(),
+		pollCtx:     context.Background(),
+		parking:     store,
+		replayReady: make(chan struct{}, 1),
+	}
+
+	// Queued but not routed when the poll loop exits, then queued after
+	c.queueReplay(models.ParkedEvent{EventID: "evt-1", Parent: "order:order-1"}, false)
+	c.closeReplays()
+	c.queueReplay(models.ParkedEvent{EventID: "evt-2", Parent: "order:order-1"}, false)
+
+	if len(store.parked) != 2 || store.parked[0].EventID != "evt-1" || store.parked[1].EventID != "evt-2" {
+		t.Errorf("Expected both events parked again, got %+v", store.parked)
+	}
+}
+
+// recordingParking is a ParkingStore recording parked events
+type recordingParking struct{ parked []models.ParkedEvent }
+
+func (p *recordingParking) Park(ctx context.Context, entry models.ParkedEvent) error {
+	p.parked = append(p.parked, entry)
+	return nil
+}
+
+func (p *recordingParking) Take(ctx context.Context, parent string) ([]models.ParkedEvent, error) {
+	return nil, nil
+}
+
+func (p *recordingParking) Expired(ctx context.Context, cutoff time.Time) ([]string, error) {
+	return nil, nil
+}
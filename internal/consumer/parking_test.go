@@ -0,0 +1,177 @@
+package consumer_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"event-pipeline/internal/config"
+	"event-pipeline/internal/consumer"
+	"event-pipeline/internal/database"
+	"event-pipeline/internal/models"
+)
+
+// memoryParking is a ParkingStore holding parked events in a map
+type memoryParking struct {
+	mu      sync.Mutex
+	entries map[string][]models.ParkedEvent
+	since   map[string]time.Time
+}
+
+func newMemoryParking() *memoryParking {
+	return &memoryParking{entries: make(map[string][]models.ParkedEvent), since: make(map[string]time.Time)}
+}
+
+func (p *memoryParking) Park(ctx context.Context, entry models.ParkedEvent) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.entries[entry.Parent] = append(p.entries[entry.Parent], entry)
+	if _, ok := p.since[entry.Parent]; !ok {
+		p.since[entry.Parent] = entry.ParkedAt
+	}
+	return nil
+}
+
+func (p *memoryParking) Take(ctx context.Context, parent string) ([]models.ParkedEvent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	entries := p.entries[parent]
+	delete(p.entries, parent)
+	delete(p.since, parent)
+	return entries, nil
+}
+
+func (p *memoryParking) Expired(ctx context.Context, cutoff time.Time) ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var parents []string
+	for parent, since := range p.since {
+		if !since.After(cutoff) {
+			parents = append(parents, parent)
+		}
+	}
+	return parents, nil
+}
+
+func (p *memoryParking) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.entries)
+}
+
+// familyEvent is a test event that writes a parent row or references one
+type familyEvent struct {
+	models.BaseEvent
+	Parent string `json:"parent"`
+}
+
+// familyHandlers writes "Parent" events as rows and rejects "Child" events
+// whose parent row has not been written
+type familyHandlers struct {
+	mu       sync.Mutex
+	parents  map[string]bool
+	children []string
+}
+
+func (h *familyHandlers) Handle(ctx context.Context, base models.BaseEvent, data []byte) error {
+	var event familyEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if base.EventType == "Parent" {
+		h.parents[event.Parent] = true
+		return nil
+	}
+	if !h.parents[event.Parent] {
+		return &database.MissingParentError{
+			Parent: database.OrderParent(event.Parent),
+			Err:    errors.New("FOREIGN KEY constraint violated"),
+		}
+	}
+	h.children = append(h.children, base.EventID)
+	return nil
+}
+
+func (h *familyHandlers) Provides(base models.BaseEvent, data []byte) (database.Parent, bool) {
+	var event familyEvent
+	if base.EventType != "Parent" || json.Unmarshal(data, &event) != nil {
+		return database.Parent{}, false
+	}
+	return database.OrderParent(event.Parent), true
+}
+
+func (h *familyHandlers) written() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.children...)
+}
+
+func familyMessage(t *testing.T, eventID string, eventType models.EventType, parent string) []byte {
+	t.Helper()
+	data, err := json.Marshal(familyEvent{
+		BaseEvent: models.BaseEvent{EventID: eventID, EventType: eventType, Timestamp: time.Now()},
+		Parent:    parent,
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal event: %v", err)
+	}
+	return data
+}
+
+func TestParkReplayAndExpire(t *testing.T) {
+	// evt-1 arrives before its parent and is replayed once evt-2 writes it;
+	// evt-3's parent never arrives
+	source := newMemorySource(
+		familyMessage(t, "evt-1", "Child", "order-1"),
+		familyMessage(t, "evt-2", "Parent", "order-1"),
+		familyMessage(t, "evt-3", "Child", "order-2"),
+	)
+	dlqClient := &memoryDLQ{}
+	store := newMemoryParking()
+	handlers := &familyHandlers{parents: make(map[string]bool)}
+
+	cfg := &config.KafkaConfig{Workers: 1, BatchSize: 1, RetryMaxAttempts: 1}
+	c, err := consumer.NewWithSource(cfg, source, "events", &memoryStore{}, dlqClient)
+	if err != nil {
+		t.Fatalf("Failed to create consumer: %v", err)
+	}
+	defer c.Stop()
+	c.Register("Parent", handlers)
+	c.Register("Child", handlers)
+	c.SetParking(store, 200*time.Millisecond)
+
+	go c.Start()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(handlers.written()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := handlers.written(); len(got) != 1 || got[0] != "evt-1" {
+		t.Fatalf("Expected evt-1 to be replayed once its parent was written, got %v", got)
+	}
+
+	for dlqClient.count() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if dlqClient.count() != 1 || dlqClient.entries[0].EventID != "evt-3" {
+		t.Fatalf("Expected evt-3 to be dead-lettered once its parking timed out, got %+v", dlqClient.entries)
+	}
+	if store.count() != 0 {
+		t.Errorf("Expected no events left parked, got %d parents", store.count())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if report := c.Drain(ctx); !report.Completed {
+		t.Fatalf("Expected drain to complete, got %+v", report)
+	}
+	if source.committed[0] != 3 {
+		t.Errorf("Expected commit at offset 3, got %d", source.committed[0])
+	}
+}
@@ -0,0 +1,144 @@
+package consumer
+
+import (
+	"sort"
+	"time"
+
+	"event-pipeline/internal/logger"
+	"event-pipeline/internal/metrics"
+
+	"github.com/sirupsen/logrus"
+)
+
+// revokeTimeout bounds how long a revocation waits for in-flight work on the
+// revoked partitions; it must stay well under max.poll.interval.ms
+const revokeTimeout = 10 * time.Second
+
+// PartitionAssignment describes a partition currently owned by the consumer
+type PartitionAssignment struct {
+	Topic      string    `json:"topic"`
+	Partition  int32     `json:"partition"`
+	AssignedAt time.Time `json:"assignedAt"`
+	Pending    int       `json:"pending"`
+	Committed  int64     `json:"committedOffset"`
+	Paused     bool      `json:"paused"`
+}
+
+// onAssigned records newly assigned partitions. It runs on the poll
+// goroutine and returns true while the consumer is paused by an operator, so
+// the source keeps the partitions paused.
+func (c *Consumer) onAssigned(partitions []TopicPartition) bool {
+	now := time.Now()
+
+	c.mu.Lock()
+	for _, tp := range partitions {
+		c.assigned[keyOf(tp)] = now
+	}
+	total := len(c.assigned)
+	pausedAll := c.pausedAll
+	c.mu.Unlock()
+
+	metrics.PartitionRebalances.WithLabelValues("assigned").Add(float64(len(partitions)))
+	metrics.AssignedPartitions.Set(float64(total))
+
+	logger.Log.WithFields(logrus.Fields{
+		"partitions": partitionList(partitions),
+		"total":      total,
+	}).Info("Partitions assigned")
+
+	return pausedAll
+}
+
+// onRevoked finishes in-flight work on revoked partitions and commits it
+// before ownership moves to another consumer. If the assignment was lost,
+// committing is no longer possible and the work will be redelivered.
+func (c *Consumer) onRevoked(partitions []TopicPartition, lost bool) {
+	keys := make([]partitionKey, 0, len(partitions))
+	for _, tp := range partitions {
+		keys = append(keys, keyOf(tp))
+	}
+
+	if !lost {
+		deadline := time.Now().Add(revokeTimeout)
+		for c.offsets.pendingIn(keys) > 0 && time.Now().Before(deadline) && c.ctx.Err() == nil {
+			time.Sleep(10 * time.Millisecond)
+		}
+		c.commitOffsets()
+	}
+
+	pending := c.offsets.pendingIn(keys)
+	c.offsets.revoke(keys)
+
+	c.mu.Lock()
+	for _, key := range keys {
+		delete(c.assigned, key)
+		delete(c.paused, key)
+	}
+	total := len(c.assigned)
+	c.mu.Unlock()
+
+	reason := "revoked"
+	if lost {
+		reason = "lost"
+	}
+	metrics.PartitionRebalances.WithLabelValues(reason).Add(float64(len(partitions)))
+	metrics.AssignedPartitions.Set(float64(total))
+
+	entry := logger.Log.WithFields(logrus.Fields{
+		"partitions": partitionList(partitions),
+		"lost":       lost,
+		"abandoned":  pending,
+		"total":      total,
+	})
+	if lost || pending > 0 {
+		entry.Warn("Partitions revoked with uncommitted work")
+	} else {
+		entry.Info("Partitions revoked")
+	}
+}
+
+// AssignedPartitions returns the partitions currently owned by the consumer
+func (c *Consumer) AssignedPartitions() []PartitionAssignment {
+	c.mu.RLock()
+	assignments := make([]PartitionAssignment, 0, len(c.assigned))
+	for key, at := range c.assigned {
+		assignments = append(assignments, PartitionAssignment{
+			Topic:      key.topic,
+			Partition:  key.partition,
+			AssignedAt: at,
+			Paused:     c.pausedAll || c.paused[key] || c.backoff[key],
+		})
+	}
+	c.mu.RUnlock()
+
+	backlog := make(map[partitionKey]PartitionBacklog)
+	for _, b := range c.offsets.backlog() {
+		backlog[partitionKey{topic: b.Topic, partition: b.Partition}] = b
+	}
+	for i := range assignments {
+		a := &assignments[i]
+		b, ok := backlog[partitionKey{topic: a.Topic, partition: a.Partition}]
+		a.Committed = -1
+		if ok {
+			a.Pending = b.Pending
+			a.Committed = b.Committed
+		}
+	}
+
+	sort.Slice(assignments, func(i, j int) bool {
+		if assignments[i].Topic != assignments[j].Topic {
+			return assignments[i].Topic < assignments[j].Topic
+		}
+		return assignments[i].Partition < assignments[j].Partition
+	})
+	return assignments
+}
+
+// partitionList renders partition numbers for logging
+func partitionList(partitions []TopicPartition) []int32 {
+	ids := make([]int32, 0, len(partitions))
+	for _, tp := range partitions {
+		ids = append(ids, tp.Partition)
+	}
+	return ids
+}
@@ -0,0 +1,86 @@
+package consumer
+
+import (
+	"time"
+
+	"event-pipeline/internal/logger"
+	"event-pipeline/internal/metrics"
+)
+
+// pollPanicBackoff is how long the poll loop waits after recovering a panic
+const pollPanicBackoff = time.Second
+
+// reconnectPolicy spaces out attempts to reopen a source after a fatal error
+var reconnectPolicy = RetryPolicy{
+	BaseBackoff: time.Second,
+	MaxBackoff:  30 * time.Second,
+	Jitter:      0.2,
+}
+
+// reconnect handles a fatal source error. The partitions of the failed
+// connection are treated as lost, then the source is reopened with backoff
+// until it succeeds or the consumer stops. Sources that cannot be reopened
+// are read again after a backoff in case they recover.
+func (c *Consumer) reconnect(cause error) {
+	logger.Log.Errorf("Fatal consumer error, reconnecting: %v", cause)
+	c.setSourceErr(cause)
+
+	// The group moves the partitions on without us; nothing can be committed
+	if partitions := c.assignedPartitions(); len(partitions) > 0 {
+		c.onRevoked(partitions, true)
+	}
+
+	reopener, ok := c.source.(Reopener)
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-c.pollCtx.Done():
+			return
+		case <-time.After(reconnectPolicy.Backoff(attempt - 1)):
+		}
+		if !ok {
+			return
+		}
+
+		err := reopener.Reopen()
+		if err == nil {
+			break
+		}
+		logger.Log.Errorf("Failed to reconnect consumer (attempt %d): %v", attempt, err)
+		c.setSourceErr(err)
+	}
+
+	c.mu.Lock()
+	c.reconnects++
+	c.mu.Unlock()
+	metrics.ConsumerReconnects.WithLabelValues(c.topic).Inc()
+	logger.Log.Info("Consumer reconnected")
+	c.setSourceErr(nil)
+}
+
+// setSourceErr records whether the source is usable
+func (c *Consumer) setSourceErr(err error) {
+	c.mu.Lock()
+	changed := (c.sourceErr == nil) != (err == nil)
+	c.sourceErr = err
+	c.mu.Unlock()
+
+	if changed {
+		connected := 0.0
+		if err == nil {
+			connected = 1
+		}
+		metrics.ConsumerConnected.WithLabelValues(c.topic).Set(connected)
+	}
+}
+
+// assignedPartitions returns the partitions the consumer has been assigned
+func (c *Consumer) assignedPartitions() []TopicPartition {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	partitions := make([]TopicPartition, 0, len(c.assigned))
+	for key := range c.assigned {
+		partitions = append(partitions, TopicPartition{Topic: key.topic, Partition: key.partition})
+	}
+	return partitions
+}
@@ -0,0 +1,75 @@
+package consumer_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"event-pipeline/internal/config"
+	"event-pipeline/internal/consumer"
+)
+
+// failingSource fails fatally on its first read and panics on its second,
+// then serves messages once reopened
+type failingSource struct {
+	*memorySource
+
+	mu      sync.Mutex
+	reads   int
+	reopens int
+}
+
+func (s *failingSource) Read(timeout time.Duration) (*consumer.Message, error) {
+	s.mu.Lock()
+	s.reads++
+	reads := s.reads
+	s.mu.Unlock()
+
+	switch reads {
+	case 1:
+		return nil, consumer.Fatal(errors.New("broker gone"))
+	case 2:
+		panic("unexpected")
+	}
+	return s.memorySource.Read(timeout)
+}
+
+func (s *failingSource) Reopen() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reopens++
+	return nil
+}
+
+func TestConsumerReconnectsAfterFatalError(t *testing.T) {
+	source := &failingSource{memorySource: newMemorySource([]byte(`{"eventId":"evt-1","eventType":"Unknown"}`))}
+	dlqClient := &memoryDLQ{}
+
+	cfg := &config.KafkaConfig{Workers: 1, BatchSize: 1, RetryMaxAttempts: 1}
+	c, err := consumer.NewWithSource(cfg, source, "events", &memoryStore{}, dlqClient)
+	if err != nil {
+		t.Fatalf("Failed to create consumer: %v", err)
+	}
+	defer c.Stop()
+
+	go c.Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for dlqClient.count() == 0 && ctx.Err() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	state := c.State()
+	if state.State != consumer.StateRunning || state.Reconnects != 1 {
+		t.Errorf("Expected a running consumer after one reconnect, got %+v", state)
+	}
+	if source.reopens != 1 {
+		t.Errorf("Expected one reopen, got %d", source.reopens)
+	}
+	if dlqClient.count() != 1 {
+		t.Errorf("Expected the message to be processed after reconnecting, got %d DLQ entries", dlqClient.count())
+	}
+}
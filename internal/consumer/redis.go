@@ -0,0 +1,452 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"event-pipeline/internal/config"
+	"event-pipeline/internal/logger"
+	"event-pipeline/internal/streams"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/sirupsen/logrus"
+)
+
+// redisReadCount bounds the entries fetched from Redis per read
+const redisReadCount = 100
+
+// RedisSource reads a topic from Redis Streams as a member of a consumer
+// group. Each shard stream of the topic is presented as a partition, with
+// offsets derived from entry IDs.
+//
+// Redis spreads the entries of a stream across the group's consumers, so
+// per-key order is only guaranteed while a single consumer reads the group.
+// Seeking moves the whole group, and entries reclaimed from a crashed
+// consumer are processed after newer entries already delivered.
+type RedisSource struct {
+	client    *redis.Client
+	group     string
+	name      string
+	shards    int
+	claimIdle time.Duration
+
+	mu        sync.Mutex
+	topic     string
+	keys      map[string]int32
+	buffer    []*Message
+	paused    map[int32]bool
+	rewound   map[int32]bool
+	delivered map[int32]map[int64]bool
+	lastClaim time.Time
+}
+
+// NewRedisSource creates a Redis Streams source in the given consumer group
+func NewRedisSource(cfg *config.RedisConfig, group string) (*RedisSource, error) {
+	client, err := streams.Connect(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newRedisSource(client, cfg, group), nil
+}
+
+// newRedisSource creates a Redis Streams source reading through client
+func newRedisSource(client *redis.Client, cfg *config.RedisConfig, group string) *RedisSource {
+	return &RedisSource{
+		client:    client,
+		group:     group,
+		name:      cfg.StreamConsumer,
+		shards:    cfg.StreamShards,
+		claimIdle: cfg.StreamClaimIdle,
+		keys:      make(map[string]int32),
+		paused:    make(map[int32]bool),
+		rewound:   make(map[int32]bool),
+		delivered: make(map[int32]map[int64]bool),
+		lastClaim: time.Now(),
+	}
+}
+
+// Subscribe creates the consumer group on every shard of topic, queues the
+// entries this consumer left pending in a previous run and assigns all
+// shards. Redis has no rebalancing, so no shard is ever revoked.
+func (s *RedisSource) Subscribe(topic string, cb RebalanceCallbacks) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	s.setTopic(topic)
+
+	for key := range s.keys {
+		err := s.client.XGroupCreateMkStream(ctx, key, s.group, "0").Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return fmt.Errorf("failed to create consumer group on %s: %w", key, err)
+		}
+		if err := s.readPending(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	partitions, _ := s.Assignment()
+	if cb.Assigned != nil && cb.Assigned(partitions) {
+		s.Pause(partitions)
+	}
+
+	logger.Log.WithFields(logrus.Fields{
+		"topic":         topic,
+		"consumerGroup": s.group,
+		"consumer":      s.name,
+		"shards":        s.shards,
+	}).Info("Successfully created Redis Streams consumer")
+	return nil
+}
+
+// setTopic maps each shard stream of topic to the partition of its shard
+func (s *RedisSource) setTopic(topic string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.topic = topic
+	for shard := int32(0); shard < int32(s.shards); shard++ {
+		s.keys[streams.Key(topic, shard)] = shard
+	}
+}
+
+// readPending queues the entries of a stream delivered to this consumer but
+// never acknowledged, e.g. before a crash
+func (s *RedisSource) readPending(ctx context.Context, key string) error {
+	start := "0"
+	for {
+		res, err := s.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    s.group,
+			Consumer: s.name,
+			Streams:  []string{key, start},
+			Count:    redisReadCount,
+			Block:    -1,
+		}).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return fmt.Errorf("failed to read pending entries of %s: %w", key, err)
+		}
+		if len(res) == 0 || len(res[0].Messages) == 0 {
+			return nil
+		}
+
+		messages := res[0].Messages
+		s.queue(key, messages, false)
+		start = messages[len(messages)-1].ID
+	}
+}
+
+// Read returns the next buffered entry, reading more from the unpaused
+// shards when the buffer is empty
+func (s *RedisSource) Read(timeout time.Duration) (*Message, error) {
+	if msg := s.next(); msg != nil {
+		return msg, nil
+	}
+
+	if time.Since(s.lastClaim) >= s.claimIdle/2 {
+		s.lastClaim = time.Now()
+		s.reclaim()
+		if msg := s.next(); msg != nil {
+			return msg, nil
+		}
+	}
+
+	s.mu.Lock()
+	keys := make([]string, 0, len(s.keys))
+	for key, shard := range s.keys {
+		if !s.paused[shard] {
+			keys = append(keys, key)
+		}
+	}
+	s.mu.Unlock()
+
+	if len(keys) == 0 {
+		time.Sleep(timeout)
+		return nil, nil
+	}
+
+	args := make([]string, 0, 2*len(keys))
+	args = append(args, keys...)
+	for range keys {
+		args = append(args, ">")
+	}
+	if timeout < time.Millisecond {
+		timeout = time.Millisecond
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout+5*time.Second)
+	defer cancel()
+
+	res, err := s.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    s.group,
+		Consumer: s.name,
+		Streams:  args,
+		Count:    redisReadCount,
+		Block:    timeout,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from streams: %w", err)
+	}
+
+	for _, stream := range res {
+		s.queue(stream.Stream, stream.Messages, false)
+	}
+	return s.next(), nil
+}
+
+// reclaim claims entries left pending by other consumers for longer than
+// the claim idle time, typically because their consumer crashed
+func (s *RedisSource) reclaim() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for key, shard := range s.keys {
+		// Claimed entries of a rewound shard would be dropped before the
+		// rewind is redelivered, then acknowledged unprocessed
+		s.mu.Lock()
+		skip := s.paused[shard] || s.rewound[shard]
+		s.mu.Unlock()
+		if skip {
+			continue
+		}
+
+		pending, err := s.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream: key,
+			Group:  s.group,
+			Idle:   s.claimIdle,
+			Start:  "-",
+			End:    "+",
+			Count:  redisReadCount,
+		}).Result()
+		if err != nil {
+			logger.Log.Errorf("Failed to list pending entries of %s: %v", key, err)
+			continue
+		}
+
+		ids := make([]string, 0, len(pending))
+		for _, p := range pending {
+			if p.Consumer != s.name {
+				ids = append(ids, p.ID)
+			}
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		messages, err := s.client.XClaim(ctx, &redis.XClaimArgs{
+			Stream:   key,
+			Group:    s.group,
+			Consumer: s.name,
+			MinIdle:  s.claimIdle,
+			Messages: ids,
+		}).Result()
+		if err != nil {
+			logger.Log.Errorf("Failed to claim pending entries of %s: %v", key, err)
+			continue
+		}
+
+		s.queue(key, messages, true)
+		logger.Log.WithFields(logrus.Fields{
+			"stream":  key,
+			"claimed": len(messages),
+		}).Warn("Claimed entries pending on another consumer")
+	}
+}
+
+// queue buffers entries read from a stream and records them as delivered
+func (s *RedisSource) queue(key string, messages []redis.XMessage, claimed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	shard, ok := s.keys[key]
+	if !ok {
+		return
+	}
+	if !claimed && len(messages) > 0 {
+		delete(s.rewound, shard)
+	}
+
+	for _, m := range messages {
+		// Entries trimmed from the stream while pending come back empty
+		if m.Values == nil {
+			continue
+		}
+		offset, err := streams.Offset(m.ID)
+		if err != nil {
+			logger.Log.Errorf("Skipping stream entry: %v", err)
+			continue
+		}
+
+		msg := &Message{
+			TopicPartition: TopicPartition{Topic: s.topic, Partition: shard, Offset: offset},
+			Timestamp:      streams.Time(offset),
+		}
+		for field, v := range m.Values {
+			value, _ := v.(string)
+			switch {
+			case field == streams.FieldKey:
+				msg.Key = []byte(value)
+			case field == streams.FieldValue:
+				msg.Value = []byte(value)
+			case strings.HasPrefix(field, streams.HeaderPrefix):
+				msg.Headers = append(msg.Headers, Header{
+					Key:   strings.TrimPrefix(field, streams.HeaderPrefix),
+					Value: []byte(value),
+				})
+			}
+		}
+
+		if s.delivered[shard] == nil {
+			s.delivered[shard] = make(map[int64]bool)
+		}
+		s.delivered[shard][offset] = true
+		s.buffer = append(s.buffer, msg)
+	}
+}
+
+// next pops the next buffered entry
+func (s *RedisSource) next() *Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.buffer) == 0 {
+		return nil
+	}
+	msg := s.buffer[0]
+	s.buffer = s.buffer[1:]
+	return msg
+}
+
+// Commit acknowledges every delivered entry below the committed offsets
+func (s *RedisSource) Commit(offsets []TopicPartition) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, tp := range offsets {
+		s.mu.Lock()
+		var acked []int64
+		var ids []string
+		for offset := range s.delivered[tp.Partition] {
+			if offset < tp.Offset {
+				acked = append(acked, offset)
+				ids = append(ids, streams.ID(offset))
+			}
+		}
+		s.mu.Unlock()
+
+		if len(ids) == 0 {
+			continue
+		}
+		if err := s.client.XAck(ctx, streams.Key(tp.Topic, tp.Partition), s.group, ids...).Err(); err != nil {
+			return fmt.Errorf("failed to acknowledge entries: %w", err)
+		}
+
+		s.mu.Lock()
+		for _, offset := range acked {
+			delete(s.delivered[tp.Partition], offset)
+		}
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// Assignment returns every shard of the topic
+func (s *RedisSource) Assignment() ([]TopicPartition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	partitions := make([]TopicPartition, 0, len(s.keys))
+	for shard := int32(0); shard < int32(s.shards); shard++ {
+		partitions = append(partitions, TopicPartition{Topic: s.topic, Partition: shard})
+	}
+	return partitions, nil
+}
+
+// Pause stops reading from shards. Entries already buffered are still returned.
+func (s *RedisSource) Pause(partitions []TopicPartition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, tp := range partitions {
+		s.paused[tp.Partition] = true
+	}
+	return nil
+}
+
+// Resume restarts reading from shards
+func (s *RedisSource) Resume(partitions []TopicPartition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, tp := range partitions {
+		delete(s.paused, tp.Partition)
+	}
+	return nil
+}
+
+// Seek moves the group's position in a shard so that tp.Offset is the next
+// entry delivered, discarding buffered entries of the shard. Entries
+// delivered again stay pending until they are committed.
+func (s *RedisSource) Seek(tp TopicPartition) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	id := "0"
+	if tp.Offset > 0 {
+		id = streams.ID(tp.Offset - 1)
+	}
+	if err := s.client.XGroupSetID(ctx, streams.Key(tp.Topic, tp.Partition), s.group, id).Err(); err != nil {
+		return fmt.Errorf("failed to move consumer group: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.buffer[:0]
+	for _, msg := range s.buffer {
+		if msg.TopicPartition.Partition != tp.Partition {
+			kept = append(kept, msg)
+		}
+	}
+	s.buffer = kept
+	s.rewound[tp.Partition] = true
+	return nil
+}
+
+// OffsetForTime returns the offset of the first entry added at or after t,
+// or the offset after the last entry if there is none
+func (s *RedisSource) OffsetForTime(tp TopicPartition, t time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), adminTimeout)
+	defer cancel()
+
+	key := streams.Key(tp.Topic, tp.Partition)
+	entries, err := s.client.XRangeN(ctx, key, streams.ID(streams.TimeOffset(t)), "+", 1).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up offset for time: %w", err)
+	}
+	if len(entries) == 1 {
+		return streams.Offset(entries[0].ID)
+	}
+
+	// No entry at or after t
+	entries, err = s.client.XRevRangeN(ctx, key, "+", "-", 1).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to query stream end: %w", err)
+	}
+	if len(entries) == 0 {
+		return 0, nil
+	}
+	last, err := streams.Offset(entries[0].ID)
+	if err != nil {
+		return 0, err
+	}
+	return last + 1, nil
+}
+
+// Close closes the Redis connection. Unacknowledged entries stay pending
+// and are read again on restart or claimed by another consumer.
+func (s *RedisSource) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,99 @@
+package consumer
+
+import (
+	"testing"
+	"time"
+
+	"event-pipeline/internal/config"
+	"event-pipeline/internal/streams"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func newTestRedisSource(shards int) *RedisSource {
+	s := newRedisSource(nil, &config.RedisConfig{StreamShards: shards, StreamClaimIdle: time.Minute}, "group")
+	s.setTopic("events")
+	return s
+}
+
+func TestRedisSourceShardsArePartitions(t *testing.T) {
+	s := newTestRedisSource(3)
+
+	partitions, err := s.Assignment()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(partitions) != 3 {
+		t.Fatalf("Expected 3 partitions, got %v", partitions)
+	}
+	for i, tp := range partitions {
+		if tp.Topic != "events" || tp.Partition != int32(i) {
+			t.Errorf("Expected events/%d, got %s/%d", i, tp.Topic, tp.Partition)
+		}
+	}
+
+	// Entries are read from the partition of their shard stream
+	for shard := int32(0); shard < 3; shard++ {
+		s.queue(streams.Key("events", shard), []redis.XMessage{{ID: "1700000000000-0", Values: map[string]interface{}{streams.FieldValue: "{}"}}}, false)
+		msg := s.next()
+		if msg == nil || msg.TopicPartition.Topic != "events" || msg.TopicPartition.Partition != shard {
+			t.Errorf("Expected an entry of %s on partition %d, got %+v", streams.Key("events", shard), shard, msg)
+		}
+	}
+
+	// Streams of other topics or shards are not the source's
+	s.queue("orders:0", []redis.XMessage{{ID: "1-0", Values: map[string]interface{}{}}}, false)
+	s.queue(streams.Key("events", 3), []redis.XMessage{{ID: "1-0", Values: map[string]interface{}{}}}, false)
+	if msg := s.next(); msg != nil {
+		t.Errorf("Expected entries of unknown streams to be ignored, got %+v", msg)
+	}
+}
+
+func TestRedisSourceEntryIDsAreOffsets(t *testing.T) {
+	s := newTestRedisSource(2)
+	key := streams.Key("events", 1)
+
+	s.queue(key, []redis.XMessage{
+		{ID: "1700000000000-7", Values: map[string]interface{}{
+			streams.FieldKey:                 "order-1",
+			streams.FieldValue:               `{"eventId":"evt-1"}`,
+			streams.HeaderPrefix + "attempt": "2",
+		}},
+		// Trimmed while pending
+		{ID: "1700000000001-0"},
+		{ID: "not-an-id", Values: map[string]interface{}{streams.FieldValue: "{}"}},
+		{ID: "1700000000002-0", Values: map[string]interface{}{streams.FieldValue: "{}"}},
+	}, false)
+
+	msg := s.next()
+	if msg == nil {
+		t.Fatal("Expected a buffered entry")
+	}
+	want, _ := streams.Offset("1700000000000-7")
+	if msg.TopicPartition.Offset != want || streams.ID(msg.TopicPartition.Offset) != "1700000000000-7" {
+		t.Errorf("Expected offset %d for entry 1700000000000-7, got %d", want, msg.TopicPartition.Offset)
+	}
+	if !msg.Timestamp.Equal(time.UnixMilli(1700000000000)) {
+		t.Errorf("Expected the entry's time from its ID, got %s", msg.Timestamp)
+	}
+	if string(msg.Key) != "order-1" || string(msg.Value) != `{"eventId":"evt-1"}` {
+		t.Errorf("Unexpected key %q or value %q", msg.Key, msg.Value)
+	}
+	if len(msg.Headers) != 1 || msg.Headers[0].Key != "attempt" || string(msg.Headers[0].Value) != "2" {
+		t.Errorf("Expected an attempt header, got %+v", msg.Headers)
+	}
+
+	// Trimmed and malformed entries are skipped
+	next := s.next()
+	if want, _ := streams.Offset("1700000000002-0"); next == nil || next.TopicPartition.Offset != want {
+		t.Errorf("Expected entry 1700000000002-0 next, got %+v", next)
+	}
+	if extra := s.next(); extra != nil {
+		t.Errorf("Expected no more entries, got %+v", extra)
+	}
+
+	// Delivered entries are tracked by offset for acknowledgement on commit
+	if got := len(s.delivered[1]); got != 2 || !s.delivered[1][want] {
+		t.Errorf("Expected 2 delivered entries including %d, got %v", want, s.delivered[1])
+	}
+}
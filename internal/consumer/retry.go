@@ -0,0 +1,106 @@
+package consumer
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"event-pipeline/internal/config"
+	"event-pipeline/internal/logger"
+	"event-pipeline/internal/metrics"
+	"event-pipeline/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RetryPolicy controls how a failing handler is retried before dead-lettering
+type RetryPolicy struct {
+	MaxAttempts int           // total attempts including the first one
+	BaseBackoff time.Duration // delay before the first retry
+	MaxBackoff  time.Duration // upper bound for any single delay
+	Jitter      float64       // random +/- fraction applied to each delay
+}
+
+// RetryPolicyFromConfig builds a RetryPolicy from Kafka configuration
+func RetryPolicyFromConfig(cfg *config.KafkaConfig) RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: cfg.RetryMaxAttempts,
+		BaseBackoff: cfg.RetryBaseBackoff,
+		MaxBackoff:  cfg.RetryMaxBackoff,
+		Jitter:      cfg.RetryJitter,
+	}
+}
+
+// Backoff returns the delay before the given retry (1 for the first retry)
+func (p RetryPolicy) Backoff(retry int) time.Duration {
+	if retry < 1 || p.BaseBackoff <= 0 {
+		return 0
+	}
+
+	d := p.BaseBackoff
+	for i := 1; i < retry && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+
+	if p.Jitter > 0 {
+		d = time.Duration(float64(d) * (1 + p.Jitter*(rand.Float64()*2-1)))
+	}
+	return d
+}
+
+// SetRetryPolicy replaces the consumer's retry policy. Events already being
+// retried keep the policy they started with.
+func (c *Consumer) SetRetryPolicy(policy RetryPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.retry = policy
+}
+
+// handleWithRetry runs handler for an event through the middleware chain,
+// retrying failures according to the retry policy. Permanent failures are
+// not retried. ctx must derive from the consumer's context. It returns the
+// number of attempts made and the last error.
+func (c *Consumer) handleWithRetry(ctx context.Context, handler Handler, base models.BaseEvent, data []byte) (int, error) {
+	handler = c.wrap(handler)
+
+	c.mu.RLock()
+	policy := c.retry
+	c.mu.RUnlock()
+
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	var err error
+	attempt := 0
+	for attempt < maxAttempts {
+		attempt++
+
+		err = handler.Handle(ctx, base, data)
+
+		if err == nil || attempt == maxAttempts || Classify(err) == ErrorPermanent {
+			break
+		}
+
+		backoff := policy.Backoff(attempt)
+		logger.WithEventID(base.EventID).WithFields(logrus.Fields{
+			"attempt": attempt,
+			"backoff": backoff.String(),
+			"error":   err.Error(),
+			"class":   Classify(err).String(),
+		}).Warn("Handler failed, retrying")
+		metrics.ConsumerRetries.WithLabelValues(string(base.EventType)).Inc()
+
+		select {
+		case <-c.ctx.Done():
+			return attempt, err
+		case <-time.After(backoff):
+		}
+	}
+
+	return attempt, err
+}
@@ -0,0 +1,95 @@
+package consumer_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"event-pipeline/internal/config"
+	"event-pipeline/internal/consumer"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := consumer.RetryPolicy{BaseBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	tests := []struct {
+		name   string
+		policy consumer.RetryPolicy
+		retry  int
+		want   time.Duration
+	}{
+		{"no retry yet", policy, 0, 0},
+		{"negative retry", policy, -1, 0},
+		{"first retry", policy, 1, 100 * time.Millisecond},
+		{"doubles", policy, 2, 200 * time.Millisecond},
+		{"doubles again", policy, 4, 800 * time.Millisecond},
+		{"capped", policy, 5, time.Second},
+		{"stays capped", policy, 60, time.Second},
+		{"uncapped", consumer.RetryPolicy{BaseBackoff: 100 * time.Millisecond}, 6, 3200 * time.Millisecond},
+		{"no base backoff", consumer.RetryPolicy{MaxBackoff: time.Second}, 3, 0},
+	}
+
+	for _, tt := range tests {
+		if got := tt.policy.Backoff(tt.retry); got != tt.want {
+			t.Errorf("%s: Backoff(%d) = %s, want %s", tt.name, tt.retry, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicyBackoffJitter(t *testing.T) {
+	policy := consumer.RetryPolicy{BaseBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Jitter: 0.2}
+	tests := []struct {
+		retry    int
+		min, max time.Duration
+	}{
+		{1, 80 * time.Millisecond, 120 * time.Millisecond},
+		{3, 320 * time.Millisecond, 480 * time.Millisecond},
+		// Jitter is applied after the cap
+		{10, 800 * time.Millisecond, 1200 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			if got := policy.Backoff(tt.retry); got < tt.min || got > tt.max {
+				t.Fatalf("Backoff(%d) = %s, want between %s and %s", tt.retry, got, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestSetRetryPolicyWhileRunning(t *testing.T) {
+	var values [][]byte
+	for i := 0; i < 20; i++ {
+		values = append(values, userEvent(t, fmt.Sprintf("evt-%d", i)))
+	}
+	source := newMemorySource(values...)
+	store := &memoryStore{}
+
+	cfg := &config.KafkaConfig{Workers: 4, BatchSize: 1, RetryMaxAttempts: 1}
+	c, err := consumer.NewWithSource(cfg, source, "events", store, &memoryDLQ{})
+	if err != nil {
+		t.Fatalf("Failed to create consumer: %v", err)
+	}
+	defer c.Stop()
+
+	go c.Start()
+
+	// Workers read the policy while it is replaced
+	for i := 0; i < 20; i++ {
+		c.SetRetryPolicy(consumer.RetryPolicy{MaxAttempts: 2, BaseBackoff: time.Millisecond})
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(store.log()) < len(values) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if report := c.Drain(ctx); !report.Completed {
+		t.Fatalf("Expected drain to complete, got %+v", report)
+	}
+	if got := len(store.log()); got != len(values) {
+		t.Errorf("Expected %d users stored, got %d", len(values), got)
+	}
+}
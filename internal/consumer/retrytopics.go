@@ -0,0 +1,158 @@
+package consumer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"event-pipeline/internal/config"
+	"event-pipeline/internal/logger"
+	"event-pipeline/internal/metrics"
+	"event-pipeline/internal/models"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/sirupsen/logrus"
+)
+
+// Headers carried by messages forwarded to retry topics
+const (
+	headerRetryAttempts = "x-retry-attempts"
+	headerOriginalTopic = "x-original-topic"
+	headerLastError     = "x-last-error"
+)
+
+// maxErrorHeaderLen bounds the error text copied into a retry header
+const maxErrorHeaderLen = 1024
+
+// RetryTier is a retry topic whose messages are processed after a delay
+type RetryTier struct {
+	Topic string
+	Delay time.Duration
+}
+
+// RawPublisher publishes already encoded messages, e.g. *producer.Producer
+type RawPublisher interface {
+	PublishRaw(topic string, key, value []byte, headers []kafka.Header) error
+}
+
+// RetryRouter forwards failed messages through the retry tiers in order
+type RetryRouter struct {
+	producer RawPublisher
+	topic    string
+	tiers    []RetryTier
+}
+
+// NewRetryRouter creates a router for the retry tiers in cfg
+func NewRetryRouter(cfg *config.KafkaConfig, p RawPublisher) *RetryRouter {
+	tiers := make([]RetryTier, 0, len(cfg.RetryTiers))
+	for _, delay := range cfg.RetryTiers {
+		tiers = append(tiers, RetryTier{Topic: cfg.RetryTopic(delay), Delay: delay})
+	}
+	return &RetryRouter{producer: p, topic: cfg.Topic, tiers: tiers}
+}
+
+// Tiers returns the configured retry tiers
+func (r *RetryRouter) Tiers() []RetryTier {
+	return r.tiers
+}
+
+// forward publishes msg to the tier after from (-1 for the main topic). It
+// returns false if there is no further tier.
+func (r *RetryRouter) forward(msg *Message, from, attempts int, cause error) (bool, error) {
+	next := from + 1
+	if next >= len(r.tiers) {
+		return false, nil
+	}
+
+	errText := cause.Error()
+	if len(errText) > maxErrorHeaderLen {
+		errText = errText[:maxErrorHeaderLen]
+	}
+	headers := []kafka.Header{
+		{Key: headerRetryAttempts, Value: []byte(strconv.Itoa(attempts))},
+		{Key: headerOriginalTopic, Value: []byte(r.topic)},
+		{Key: headerLastError, Value: []byte(errText)},
+	}
+
+	if err := r.producer.PublishRaw(r.tiers[next].Topic, msg.Key, msg.Value, headers); err != nil {
+		return true, fmt.Errorf("failed to forward to %s: %w", r.tiers[next].Topic, err)
+	}
+	return true, nil
+}
+
+// SetRetryRouter routes failed messages through retry topics before the DLQ
+func (c *Consumer) SetRetryRouter(router *RetryRouter) {
+	c.router = router
+}
+
+// NewRetryTierConsumer creates a consumer for one retry tier. It runs in its
+// own consumer group and only processes messages once the tier delay has
+// elapsed. It starts with the default handlers; use CopyHandlers to give it
+// those of the main consumer.
+func NewRetryTierConsumer(cfg *config.KafkaConfig, router *RetryRouter, tier int, db Store, dlqClient DeadLetterQueue) (*Consumer, error) {
+	if tier < 0 || tier >= len(router.tiers) {
+		return nil, fmt.Errorf("retry tier %d out of range", tier)
+	}
+
+	t := router.tiers[tier]
+	c, err := newKafkaConsumer(cfg, t.Topic, cfg.ConsumerGroup+"."+strings.TrimPrefix(t.Topic, cfg.Topic+"."), db, dlqClient)
+	if err != nil {
+		return nil, err
+	}
+	c.router = router
+	c.tier = tier
+	return c, nil
+}
+
+// deferUntilDue pauses the message's partition until the retry tier delay
+// has elapsed since the message was produced. Messages in a tier are in
+// time order, so nothing behind the deferred message is due either.
+func (c *Consumer) deferUntilDue(msg *Message) bool {
+	if c.tier < 0 || msg.Timestamp.IsZero() {
+		return false
+	}
+
+	wait := time.Until(msg.Timestamp.Add(c.router.tiers[c.tier].Delay))
+	if wait <= 0 {
+		return false
+	}
+
+	c.pausePartition(msg, wait)
+	return true
+}
+
+// fail sends a message that could not be processed to the next retry tier,
+// or to the DLQ when it is permanent or no tier remains
+func (c *Consumer) fail(msg *Message, base models.BaseEvent, err error, attempts int) {
+	total := priorAttempts(msg) + attempts
+
+	if c.router != nil && Classify(err) != ErrorPermanent {
+		forwarded, fwdErr := c.router.forward(msg, c.tier, total, err)
+		if fwdErr == nil && forwarded {
+			metrics.RetryTopicForwards.WithLabelValues(string(base.EventType), strconv.Itoa(c.tier+1)).Inc()
+			logger.WithEventID(base.EventID).WithFields(logrus.Fields{
+				"tier":     c.tier + 1,
+				"attempts": total,
+			}).Warn("Event forwarded to retry topic")
+			return
+		}
+		if fwdErr != nil {
+			logger.WithEventID(base.EventID).Errorf("Failed to forward to retry topic, dead-lettering: %v", fwdErr)
+		}
+	}
+
+	c.sendToDLQ(base.EventID, string(msg.Value), err.Error(), total)
+}
+
+// priorAttempts returns the attempts recorded by earlier retry tiers
+func priorAttempts(msg *Message) int {
+	for _, h := range msg.Headers {
+		if h.Key == headerRetryAttempts {
+			if n, err := strconv.Atoi(string(h.Value)); err == nil {
+				return n
+			}
+		}
+	}
+	return 0
+}
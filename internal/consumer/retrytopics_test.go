@@ -0,0 +1,137 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"event-pipeline/internal/config"
+	"event-pipeline/internal/models"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// publishedRaw is a message sent through a recordingPublisher
+type publishedRaw struct {
+	topic   string
+	headers map[string]string
+}
+
+// recordingPublisher records the messages published to it
+type recordingPublisher struct {
+	published []publishedRaw
+	err       error
+}
+
+func (p *recordingPublisher) PublishRaw(topic string, key, value []byte, headers []kafka.Header) error {
+	if p.err != nil {
+		return p.err
+	}
+	h := make(map[string]string, len(headers))
+	for _, header := range headers {
+		h[header.Key] = string(header.Value)
+	}
+	p.published = append(p.published, publishedRaw{topic: topic, headers: h})
+	return nil
+}
+
+func TestRetryTopic(t *testing.T) {
+	cfg := &config.KafkaConfig{Topic: "events"}
+	tests := []struct {
+		delay time.Duration
+		want  string
+	}{
+		{30 * time.Second, "events.retry.30s"},
+		{5 * time.Minute, "events.retry.5m"},
+		{90 * time.Second, "events.retry.1m30s"},
+		{time.Hour, "events.retry.1h"},
+		{90 * time.Minute, "events.retry.1h30m"},
+	}
+	for _, tt := range tests {
+		if got := cfg.RetryTopic(tt.delay); got != tt.want {
+			t.Errorf("RetryTopic(%s) = %s, want %s", tt.delay, got, tt.want)
+		}
+	}
+}
+
+func TestRetryRouterForward(t *testing.T) {
+	pub := &recordingPublisher{}
+	cfg := &config.KafkaConfig{Topic: "events", RetryTiers: []time.Duration{30 * time.Second, 5 * time.Minute}}
+	router := NewRetryRouter(cfg, pub)
+	msg := &Message{Key: []byte("user-1"), Value: []byte(`{}`)}
+
+	// The main topic forwards to the first tier, each tier to the next
+	for from, want := range []string{"events.retry.30s", "events.retry.5m"} {
+		forwarded, err := router.forward(msg, from-1, 3*(from+1), errors.New("timeout"))
+		if err != nil || !forwarded {
+			t.Fatalf("Expected forward from %d, got %v, %v", from-1, forwarded, err)
+		}
+		if got := pub.published[from].topic; got != want {
+			t.Errorf("Expected forward from %d to %s, got %s", from-1, want, got)
+		}
+	}
+
+	headers := pub.published[1].headers
+	if headers[headerRetryAttempts] != "6" || headers[headerOriginalTopic] != "events" || headers[headerLastError] != "timeout" {
+		t.Errorf("Unexpected headers %v", headers)
+	}
+
+	// The last tier has nowhere to forward to
+	if forwarded, err := router.forward(msg, 1, 9, errors.New("timeout")); forwarded || err != nil {
+		t.Errorf("Expected no forward from the last tier, got %v, %v", forwarded, err)
+	}
+
+	// Long errors are truncated
+	router.forward(msg, -1, 1, errors.New(strings.Repeat("x", 2*maxErrorHeaderLen)))
+	if got := len(pub.published[2].headers[headerLastError]); got != maxErrorHeaderLen {
+		t.Errorf("Expected the error header to be truncated to %d bytes, got %d", maxErrorHeaderLen, got)
+	}
+
+	pub.err = errors.New("broker down")
+	if forwarded, err := router.forward(msg, -1, 1, errors.New("timeout")); !forwarded || err == nil {
+		t.Errorf("Expected a failed forward to be reported, got %v, %v", forwarded, err)
+	}
+}
+
+func TestPriorAttempts(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []Header
+		want    int
+	}{
+		{"no headers", nil, 0},
+		{"recorded", []Header{{Key: headerOriginalTopic, Value: []byte("events")}, {Key: headerRetryAttempts, Value: []byte("4")}}, 4},
+		{"malformed", []Header{{Key: headerRetryAttempts, Value: []byte("four")}}, 0},
+	}
+	for _, tt := range tests {
+		if got := priorAttempts(&Message{Headers: tt.headers}); got != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestCopyHandlers(t *testing.T) {
+	var handled []string
+	handler := func(name string) Handler {
+		return HandlerFunc(func(ctx context.Context, base models.BaseEvent, data []byte) error {
+			handled = append(handled, name)
+			return nil
+		})
+	}
+
+	main := &Consumer{handlers: make(map[models.EventType]Handler)}
+	main.Register("Custom", handler("custom"))
+	main.SetFallback(handler("fallback"))
+
+	tier := &Consumer{handlers: make(map[models.EventType]Handler)}
+	tier.Register("Custom", handler("tier"))
+	tier.CopyHandlers(main)
+
+	tier.handlerFor("Custom").Handle(context.Background(), models.BaseEvent{}, nil)
+	tier.handlerFor("Unknown").Handle(context.Background(), models.BaseEvent{}, nil)
+	if strings.Join(handled, ",") != "custom,fallback" {
+		t.Errorf("Expected the main consumer's handlers, got %v", handled)
+	}
+}
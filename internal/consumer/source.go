@@ -0,0 +1,113 @@
+package consumer
+
+import (
+	"context"
+	"time"
+
+	"event-pipeline/internal/database"
+	"event-pipeline/internal/models"
+)
+
+// TopicPartition identifies a partition of a topic and, where relevant, an
+// offset within it
+type TopicPartition struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+}
+
+// Header is a key/value pair carried alongside a message
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// Message is a message read from a Source
+type Message struct {
+	TopicPartition TopicPartition
+	Key            []byte
+	Value          []byte
+	Headers        []Header
+	Timestamp      time.Time
+}
+
+// RebalanceCallbacks are invoked by a Source on the poll goroutine as
+// partitions move between consumers of a group
+type RebalanceCallbacks struct {
+	// Assigned is called with newly assigned partitions before they are
+	// fetched; returning true asks the source to keep them paused
+	Assigned func(partitions []TopicPartition) bool
+
+	// Revoked is called before partitions move to another consumer; lost
+	// reports that they already have and can no longer be committed
+	Revoked func(partitions []TopicPartition, lost bool)
+}
+
+// Source delivers messages to the consumer and tracks its position. Read
+// and Commit are called from the poll goroutine, but workers may pause,
+// resume and seek partitions concurrently.
+type Source interface {
+	// Subscribe starts consuming topic, reporting partition movements to cb
+	Subscribe(topic string, cb RebalanceCallbacks) error
+
+	// Read returns the next message, or nil with no error if none arrived
+	// within timeout. A *FatalError means the source can no longer be used.
+	Read(timeout time.Duration) (*Message, error)
+
+	// Commit stores the offsets of the next messages to process
+	Commit(offsets []TopicPartition) error
+
+	// Assignment returns the partitions currently owned by the consumer
+	Assignment() ([]TopicPartition, error)
+
+	// Pause and Resume stop and restart fetching from partitions
+	Pause(partitions []TopicPartition) error
+	Resume(partitions []TopicPartition) error
+
+	// Seek moves a partition so its next message is at tp.Offset
+	Seek(tp TopicPartition) error
+
+	// OffsetForTime returns the offset of the first message at or after t,
+	// or the end of the partition if there is none
+	OffsetForTime(tp TopicPartition, t time.Time) (int64, error)
+
+	Close() error
+}
+
+// Reopener is a Source that can replace its connection after a fatal error.
+// The new connection subscribes to the same topic with the same callbacks.
+type Reopener interface {
+	Reopen() error
+}
+
+// FatalError is returned by Source.Read when the connection has failed for
+// good and must be reopened
+type FatalError struct {
+	Err error
+}
+
+func (e *FatalError) Error() string { return e.Err.Error() }
+func (e *FatalError) Unwrap() error { return e.Err }
+
+// Fatal wraps err as a FatalError
+func Fatal(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &FatalError{Err: err}
+}
+
+// Store applies events to the database projections
+type Store interface {
+	UpsertUser(ctx context.Context, event models.UserCreated) error
+	UpsertOrder(ctx context.Context, event models.OrderPlaced) error
+	UpsertPayment(ctx context.Context, event models.PaymentSettled) error
+	UpsertInventory(ctx context.Context, event models.InventoryAdjusted) error
+	WriteBatch(ctx context.Context, b *database.Batch) error
+}
+
+// DeadLetterQueue receives messages the consumer gives up on
+type DeadLetterQueue interface {
+	Push(ctx context.Context, eventID, originalData, errorMsg string, retryCount int) error
+	PushInvalid(ctx context.Context, eventID, originalData string, verr *models.ValidationError) error
+}
@@ -0,0 +1,112 @@
+package consumer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"event-pipeline/internal/logger"
+	"event-pipeline/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// HeaderTraceparent carries a W3C trace context on a message
+const HeaderTraceparent = "traceparent"
+
+// Span identifies one handler call within a trace
+type Span struct {
+	TraceID  string
+	SpanID   string
+	ParentID string // empty when the call started the trace
+}
+
+type spanKey struct{}
+
+type headersKey struct{}
+
+// withHeaders returns a context carrying the headers of the message handled
+func withHeaders(ctx context.Context, headers []Header) context.Context {
+	return context.WithValue(ctx, headersKey{}, headers)
+}
+
+// MessageHeaders returns the headers of the message being handled, if any
+func MessageHeaders(ctx context.Context) []Header {
+	headers, _ := ctx.Value(headersKey{}).([]Header)
+	return headers
+}
+
+// SpanFromContext returns the span of the handler call, if it is traced
+func SpanFromContext(ctx context.Context) (Span, bool) {
+	span, ok := ctx.Value(spanKey{}).(Span)
+	return span, ok
+}
+
+// Traceparent formats the span as a W3C traceparent header value, for
+// propagating the trace to events published by a handler
+func (s Span) Traceparent() string {
+	return "00-" + s.TraceID + "-" + s.SpanID + "-01"
+}
+
+// Tracing starts a span for every handler call, continuing the trace of the
+// message's traceparent header when it has a valid one. The span is passed
+// to the handler in its context and logged with its duration at debug level.
+func Tracing() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, base models.BaseEvent, data []byte) error {
+			span := Span{SpanID: randomHex(8)}
+			if traceID, parentID, ok := parseTraceparent(MessageHeaders(ctx)); ok {
+				span.TraceID, span.ParentID = traceID, parentID
+			} else {
+				span.TraceID = randomHex(16)
+			}
+
+			start := time.Now()
+			err := next.Handle(context.WithValue(ctx, spanKey{}, span), base, data)
+
+			entry := logger.WithEventID(base.EventID).WithFields(logrus.Fields{
+				"eventType": base.EventType,
+				"traceId":   span.TraceID,
+				"spanId":    span.SpanID,
+				"parentId":  span.ParentID,
+				"duration":  time.Since(start).String(),
+			})
+			if err != nil {
+				entry = entry.WithField("error", err.Error())
+			}
+			entry.Debug("Span finished")
+			return err
+		})
+	}
+}
+
+// parseTraceparent returns the trace and parent span IDs of a traceparent
+// header ("00-<32 hex>-<16 hex>-<2 hex>")
+func parseTraceparent(headers []Header) (traceID, parentID string, ok bool) {
+	for _, h := range headers {
+		if h.Key != HeaderTraceparent {
+			continue
+		}
+		parts := strings.Split(string(h.Value), "-")
+		if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 || !isHex(parts[1]) || !isHex(parts[2]) ||
+			parts[1] == strings.Repeat("0", 32) || parts[2] == strings.Repeat("0", 16) {
+			return "", "", false
+		}
+		return parts[1], parts[2], true
+	}
+	return "", "", false
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil && strings.ToLower(s) == s
+}
+
+// randomHex returns n random bytes as lowercase hex
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
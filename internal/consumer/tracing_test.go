@@ -0,0 +1,58 @@
+package consumer
+
+import (
+	"context"
+	"testing"
+
+	"event-pipeline/internal/models"
+)
+
+func TestTracing(t *testing.T) {
+	var spans []Span
+	handler := Tracing()(HandlerFunc(func(ctx context.Context, base models.BaseEvent, data []byte) error {
+		span, ok := SpanFromContext(ctx)
+		if !ok {
+			t.Fatal("Expected a span in the handler context")
+		}
+		spans = append(spans, span)
+		return nil
+	}))
+
+	const traceID, parentID = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	tests := []struct {
+		name        string
+		traceparent string
+		continued   bool
+	}{
+		{"no header", "", false},
+		{"valid", "00-" + traceID + "-" + parentID + "-01", true},
+		{"malformed", "00-" + traceID + "-01", false},
+		{"uppercase", "00-4BF92F3577B34DA6A3CE929D0E0E4736-" + parentID + "-01", false},
+		{"zero trace", "00-00000000000000000000000000000000-" + parentID + "-01", false},
+	}
+
+	for _, tt := range tests {
+		var headers []Header
+		if tt.traceparent != "" {
+			headers = []Header{{Key: HeaderTraceparent, Value: []byte(tt.traceparent)}}
+		}
+		spans = nil
+		handler.Handle(withHeaders(context.Background(), headers), models.BaseEvent{EventID: "evt-1"}, nil)
+
+		span := spans[0]
+		if len(span.TraceID) != 32 || len(span.SpanID) != 16 || span.SpanID == parentID {
+			t.Errorf("%s: unexpected span IDs %+v", tt.name, span)
+		}
+		if continued := span.TraceID == traceID && span.ParentID == parentID; continued != tt.continued {
+			t.Errorf("%s: expected continued %v, got span %+v", tt.name, tt.continued, span)
+		}
+		if !tt.continued && span.ParentID != "" {
+			t.Errorf("%s: expected a new trace without a parent, got %+v", tt.name, span)
+		}
+	}
+
+	span := Span{TraceID: traceID, SpanID: parentID}
+	if got := span.Traceparent(); got != "00-"+traceID+"-"+parentID+"-01" {
+		t.Errorf("Unexpected traceparent %s", got)
+	}
+}
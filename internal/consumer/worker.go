@@ -0,0 +1,192 @@
+package consumer
+
+import (
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+
+	"event-pipeline/internal/logger"
+	"event-pipeline/internal/metrics"
+	"event-pipeline/internal/models"
+)
+
+// workerQueueSize bounds the messages buffered per worker before the poll
+// loop blocks
+const workerQueueSize = 100
+
+// commitInterval is how often fully processed offsets are committed
+const commitInterval = time.Second
+
+// work is a message dispatched to a worker along with its partition epoch,
+// or a parked event to replay
+type work struct {
+	msg   *Message
+	key   partitionKey
+	epoch uint64
+
+	parked  *models.ParkedEvent
+	expired bool
+}
+
+// startWorkers launches the worker pool
+func (c *Consumer) startWorkers() {
+	c.queues = make([]chan work, c.workers)
+	for i := range c.queues {
+		c.queues[i] = make(chan work, workerQueueSize)
+		c.wg.Add(1)
+		go c.runWorker(c.queues[i])
+	}
+	logger.Log.Infof("Started %d consumer workers", c.workers)
+}
+
+// stopWorkers closes the worker queues; workers finish what is queued and exit
+func (c *Consumer) stopWorkers() {
+	for _, queue := range c.queues {
+		close(queue)
+	}
+}
+
+// runWorker processes messages from a single queue in order. With batching
+// enabled, messages are accumulated until the batch is full or the batch
+// window since the first message has elapsed.
+func (c *Consumer) runWorker(queue <-chan work) {
+	defer c.wg.Done()
+
+	var pending []work
+	var flush <-chan time.Time
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			c.abandon(queue, pending)
+			return
+		case w, ok := <-queue:
+			if !ok {
+				c.processBatch(pending)
+				return
+			}
+			if c.batchSize <= 1 {
+				c.processWork(w)
+				continue
+			}
+			pending = append(pending, w)
+			if len(pending) == 1 {
+				flush = time.After(c.batchWindow)
+			}
+			if len(pending) >= c.batchSize {
+				c.processBatch(pending)
+				pending, flush = nil, nil
+			}
+		case <-flush:
+			c.processBatch(pending)
+			pending, flush = nil, nil
+		}
+	}
+}
+
+// abandon waits for the queue to close once the consumer has stopped. The
+// offsets of abandoned messages stay uncommitted, but parked events taken
+// for replay have no offset, so they are parked again for the next run.
+func (c *Consumer) abandon(queue <-chan work, pending []work) {
+	for _, w := range pending {
+		if w.parked != nil {
+			c.keepParked(*w.parked)
+		}
+	}
+	for w := range queue {
+		if w.parked != nil {
+			c.keepParked(*w.parked)
+		}
+	}
+}
+
+// processWork processes a single dispatched message and marks it done, or
+// replays a parked event
+func (c *Consumer) processWork(w work) {
+	if w.parked != nil {
+		c.replay(*w.parked, w.expired)
+		return
+	}
+
+	// Skip messages made obsolete by a partition rewind or abandoned on stop
+	if c.ctx.Err() != nil || !c.offsets.current(w.key, w.msg.TopicPartition.Offset, w.epoch) {
+		return
+	}
+	if c.processMessage(w.msg, nil) {
+		c.offsets.done(w.key, w.msg.TopicPartition.Offset, w.epoch)
+	}
+}
+
+// dispatch routes a message to the worker owning its key, so that messages
+// with the same key are processed in order
+func (c *Consumer) dispatch(msg *Message) {
+	key := keyOf(msg.TopicPartition)
+	epoch, ok := c.offsets.dispatched(key, msg.TopicPartition.Offset)
+	if !ok {
+		return
+	}
+	c.enqueue(msg.Key, msg.TopicPartition.Partition, work{msg: msg, key: key, epoch: epoch})
+}
+
+// enqueue hands work to the worker owning routingKey. It returns false if
+// polling stopped before the worker had room for it.
+func (c *Consumer) enqueue(routingKey []byte, partition int32, w work) bool {
+	// Keyless messages fall back to partition ordering
+	if len(routingKey) == 0 {
+		routingKey = []byte(strconv.Itoa(int(partition)))
+	}
+	h := fnv.New32a()
+	h.Write(routingKey)
+	queue := c.queues[h.Sum32()%uint32(len(c.queues))]
+
+	select {
+	case queue <- w:
+		return true
+	case <-c.pollCtx.Done():
+		return false
+	}
+}
+
+// commitOffsets commits every partition up to its lowest unfinished offset
+func (c *Consumer) commitOffsets() {
+	offsets := c.offsets.committable()
+	if len(offsets) == 0 {
+		return
+	}
+
+	if err := c.source.Commit(offsets); err != nil {
+		logger.Log.Errorf("Failed to commit offsets: %v", err)
+		return
+	}
+	c.offsets.markCommitted(offsets)
+}
+
+// rateCounter counts processed events per type for the per-second gauge
+type rateCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func newRateCounter() *rateCounter {
+	return &rateCounter{counts: make(map[string]int)}
+}
+
+func (r *rateCounter) inc(eventType string) {
+	r.mu.Lock()
+	r.counts[eventType]++
+	r.mu.Unlock()
+}
+
+// flush publishes the counts gathered since the last flush
+func (r *rateCounter) flush() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for eventType, count := range r.counts {
+		if count > 0 {
+			metrics.MessagesProcessedPerSecond.WithLabelValues(eventType).Set(float64(count))
+			r.counts[eventType] = 0
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"event-pipeline/internal/logger"
+	"event-pipeline/internal/metrics"
+	"event-pipeline/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxParams keeps multi-row statements under SQL Server's 2100 parameter limit
+const maxParams = 2000
+
+// Batch groups events that are written together in a single transaction
+type Batch struct {
+	Users     []models.UserCreated
+	Orders    []models.OrderPlaced
+	Payments  []models.PaymentSettled
+	Inventory []models.InventoryAdjusted
+}
+
+// AddUser appends a UserCreated event to the batch
+func (b *Batch) AddUser(event models.UserCreated) { b.Users = append(b.Users, event) }
+
+// AddOrder appends an OrderPlaced event to the batch
+func (b *Batch) AddOrder(event models.OrderPlaced) { b.Orders = append(b.Orders, event) }
+
+// AddPayment appends a PaymentSettled event to the batch
+func (b *Batch) AddPayment(event models.PaymentSettled) { b.Payments = append(b.Payments, event) }
+
+// AddInventory appends an InventoryAdjusted event to the batch
+func (b *Batch) AddInventory(event models.InventoryAdjusted) {
+	b.Inventory = append(b.Inventory, event)
+}
+
+// Len returns the number of events in the batch
+func (b *Batch) Len() int {
+	return len(b.Users) + len(b.Orders) + len(b.Payments) + len(b.Inventory)
+}
+
+// bases returns the envelope of every event in the batch
+func (b *Batch) bases() []models.BaseEvent {
+	bases := make([]models.BaseEvent, 0, b.Len())
+	for _, e := range b.Users {
+		bases = append(bases, e.BaseEvent)
+	}
+	for _, e := range b.Orders {
+		bases = append(bases, e.BaseEvent)
+	}
+	for _, e := range b.Payments {
+		bases = append(bases, e.BaseEvent)
+	}
+	for _, e := range b.Inventory {
+		bases = append(bases, e.BaseEvent)
+	}
+	return bases
+}
+
+// WriteBatch writes every event in the batch in one transaction. Parents are
+// written before children (users, orders, payments) so foreign keys hold
+// within the batch. Either all events are applied or none are; events already
+// recorded in the processed_events ledger are skipped.
+func (db *DB) WriteBatch(ctx context.Context, b *Batch) error {
+	if b.Len() == 0 {
+		return nil
+	}
+
+	start := time.Now()
+	defer func() {
+		metrics.DBLatency.WithLabelValues("write_batch").Observe(time.Since(start).Seconds())
+	}()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	claimed, err := claimEventsTx(ctx, tx, b.bases())
+	if err != nil {
+		return err
+	}
+	used := make(map[string]bool, len(claimed))
+	var duplicates, skipped []models.BaseEvent
+	users, skipped := claimedOnly(b.Users, func(e models.UserCreated) models.BaseEvent { return e.BaseEvent }, claimed, used)
+	duplicates = append(duplicates, skipped...)
+	orders, skipped := claimedOnly(b.Orders, func(e models.OrderPlaced) models.BaseEvent { return e.BaseEvent }, claimed, used)
+	duplicates = append(duplicates, skipped...)
+	payments, skipped := claimedOnly(b.Payments, func(e models.PaymentSettled) models.BaseEvent { return e.BaseEvent }, claimed, used)
+	duplicates = append(duplicates, skipped...)
+	inventory, skipped := claimedOnly(b.Inventory, func(e models.InventoryAdjusted) models.BaseEvent { return e.BaseEvent }, claimed, used)
+	duplicates = append(duplicates, skipped...)
+
+	staleUsers, err := upsertUsersTx(ctx, tx, users)
+	if err != nil {
+		return err
+	}
+	var staleOrders int
+	for _, order := range orders {
+		current, err := upsertOrderTx(ctx, tx, order)
+		if err != nil {
+			return err
+		}
+		if !current {
+			staleOrders++
+		}
+	}
+	stalePayments, err := upsertPaymentsTx(ctx, tx, payments)
+	if err != nil {
+		return err
+	}
+	if err := adjustInventoryTx(ctx, tx, inventory); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit batch: %w", err)
+	}
+	recordDuplicates(duplicates)
+	recordStale(models.UserCreatedEvent, staleUsers)
+	recordStale(models.OrderPlacedEvent, staleOrders)
+	recordStale(models.PaymentSettledEvent, stalePayments)
+
+	logger.Log.WithFields(logrus.Fields{
+		"users":     len(users),
+		"orders":    len(orders),
+		"payments":  len(payments),
+		"inventory": len(inventory),
+	}).Info("Batch written successfully")
+
+	return nil
+}
+
+// upsertUsersTx merges users with one statement per chunk. When a user
+// appears more than once, the event with the highest version wins.
+func upsertUsersTx(ctx context.Context, tx *sql.Tx, events []models.UserCreated) (int, error) {
+	users, stale := newestByKey(events, func(e models.UserCreated) (string, models.BaseEvent) { return e.UserID, e.BaseEvent })
+
+	const cols = 6
+	for start := 0; start < len(users); start += maxParams / cols {
+		end := min(start+maxParams/cols, len(users))
+
+		args := make([]interface{}, 0, (end-start)*cols+1)
+		rows := make([]string, 0, end-start)
+		for _, u := range users[start:end] {
+			rows = append(rows, placeholders(len(args), cols))
+			args = append(args, u.UserID, u.Email, u.FirstName, u.LastName, u.CreatedAt, u.Version())
+		}
+		args = append(args, time.Now())
+
+		query := fmt.Sprintf(`
+			MERGE INTO users AS target
+			USING (VALUES %s) AS source (user_id, email, first_name, last_name, created_at, version)
+			ON target.user_id = source.user_id
+			WHEN MATCHED AND target.version < source.version THEN
+				UPDATE SET email = source.email, first_name = source.first_name,
+				           last_name = source.last_name, updated_at = @p%d, version = source.version
+			WHEN NOT MATCHED THEN
+				INSERT (user_id, email, first_name, last_name, created_at, updated_at, version)
+				VALUES (source.user_id, source.email, source.first_name, source.last_name, source.created_at, @p%d, source.version);
+		`, strings.Join(rows, ", "), len(args), len(args))
+
+		res, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return 0, fmt.Errorf("failed to upsert users: %w", err)
+		}
+		n, err := countStale(res, end-start)
+		if err != nil {
+			return 0, err
+		}
+		stale += n
+	}
+	return stale, nil
+}
+
+// upsertPaymentsTx merges payments with one statement per chunk. When a
+// payment appears more than once, the event with the highest version wins.
+func upsertPaymentsTx(ctx context.Context, tx *sql.Tx, events []models.PaymentSettled) (int, error) {
+	payments, stale := newestByKey(events, func(e models.PaymentSettled) (string, models.BaseEvent) { return e.PaymentID, e.BaseEvent })
+
+	const cols = 8
+	for start := 0; start < len(payments); start += maxParams / cols {
+		end := min(start+maxParams/cols, len(payments))
+
+		args := make([]interface{}, 0, (end-start)*cols+1)
+		rows := make([]string, 0, end-start)
+		for _, p := range payments[start:end] {
+			rows = append(rows, placeholders(len(args), cols))
+			args = append(args, p.PaymentID, p.OrderID, p.Amount, p.Currency, p.PaymentMethod, p.Status, p.SettledAt, p.Version())
+		}
+		args = append(args, time.Now())
+
+		query := fmt.Sprintf(`
+			MERGE INTO payments AS target
+			USING (VALUES %s) AS source (payment_id, order_id, amount, currency, payment_method, status, settled_at, version)
+			ON target.payment_id = source.payment_id
+			WHEN MATCHED AND target.version < source.version THEN
+				UPDATE SET order_id = source.order_id, amount = source.amount, currency = source.currency,
+				           payment_method = source.payment_method, status = source.status,
+				           settled_at = source.settled_at, updated_at = @p%d, version = source.version
+			WHEN NOT MATCHED THEN
+				INSERT (payment_id, order_id, amount, currency, payment_method, status, settled_at, updated_at, version)
+				VALUES (source.payment_id, source.order_id, source.amount, source.currency,
+				        source.payment_method, source.status, source.settled_at, @p%d, source.version);
+		`, strings.Join(rows, ", "), len(args), len(args))
+
+		res, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return 0, fmt.Errorf("failed to upsert payments: %w", err)
+		}
+		n, err := countStale(res, end-start)
+		if err != nil {
+			return 0, err
+		}
+		stale += n
+	}
+	return stale, nil
+}
+
+// newestByKey keeps one event per key, the one with the highest version,
+// in order of first appearance, and returns how many events were superseded
+// within the batch
+func newestByKey[E any](events []E, keyOf func(E) (string, models.BaseEvent)) (newest []E, superseded int) {
+	index := make(map[string]int, len(events))
+	for _, e := range events {
+		key, base := keyOf(e)
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(newest)
+			newest = append(newest, e)
+			continue
+		}
+
+		if _, kept := keyOf(newest[i]); base.Version() > kept.Version() {
+			newest[i] = e
+		}
+		superseded++
+	}
+	return newest, superseded
+}
+
+// adjustInventoryTx applies inventory deltas, summed per SKU, with one
+// statement per chunk
+func adjustInventoryTx(ctx context.Context, tx *sql.Tx, events []models.InventoryAdjusted) error {
+	deltas := make(map[string]int, len(events))
+	var skus []string
+	for _, e := range events {
+		if _, ok := deltas[e.SKU]; !ok {
+			skus = append(skus, e.SKU)
+		}
+		deltas[e.SKU] += inventoryDelta(e)
+	}
+
+	const cols = 2
+	for start := 0; start < len(skus); start += maxParams / cols {
+		end := min(start+maxParams/cols, len(skus))
+
+		args := make([]interface{}, 0, (end-start)*cols+1)
+		rows := make([]string, 0, end-start)
+		for _, sku := range skus[start:end] {
+			rows = append(rows, placeholders(len(args), cols))
+			args = append(args, sku, deltas[sku])
+		}
+		args = append(args, time.Now())
+
+		query := fmt.Sprintf(`
+			MERGE INTO inventory AS target
+			USING (VALUES %s) AS source (sku, delta)
+			ON target.sku = source.sku
+			WHEN MATCHED THEN
+				UPDATE SET quantity = target.quantity + source.delta, updated_at = @p%d
+			WHEN NOT MATCHED THEN
+				INSERT (sku, quantity, updated_at)
+				VALUES (source.sku, source.delta, @p%d);
+		`, strings.Join(rows, ", "), len(args), len(args))
+
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to adjust inventory: %w", err)
+		}
+	}
+	return nil
+}
+
+// placeholders renders a VALUES row of n parameters numbered after offset
+func placeholders(offset, n int) string {
+	params := make([]string, n)
+	for i := range params {
+		params[i] = fmt.Sprintf("@p%d", offset+i+1)
+	}
+	return "(" + strings.Join(params, ", ") + ")"
+}
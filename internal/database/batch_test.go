@@ -0,0 +1,252 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"event-pipeline/internal/metrics"
+	"event-pipeline/internal/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// sqlServerMaxParams is SQL Server's limit on parameters per statement
+const sqlServerMaxParams = 2100
+
+// recordedStmt is a statement run against a recordingConnector
+type recordedStmt struct {
+	query string
+	args  []driver.Value
+}
+
+// recordingConnector is a driver that records every statement, reports every
+// row as affected and returns no rows from queries. Commits fail with
+// commitErr when it is set, and no rows are affected when noneAffected is.
+type recordingConnector struct {
+	mu           sync.Mutex
+	stmts        []recordedStmt
+	commitErr    error
+	noneAffected bool
+}
+
+func (c *recordingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &recordingConn{c: c}, nil
+}
+
+func (c *recordingConnector) Driver() driver.Driver { return nil }
+
+func (c *recordingConnector) record(query string, args []driver.Value) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stmts = append(c.stmts, recordedStmt{query: query, args: args})
+}
+
+// matching returns the recorded statements containing s
+func (c *recordingConnector) matching(s string) []recordedStmt {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var out []recordedStmt
+	for _, stmt := range c.stmts {
+		if strings.Contains(stmt.query, s) {
+			out = append(out, stmt)
+		}
+	}
+	return out
+}
+
+type recordingConn struct{ c *recordingConnector }
+
+func (c *recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return &recordingStmt{c: c.c, query: query}, nil
+}
+func (c *recordingConn) Close() error              { return nil }
+func (c *recordingConn) Begin() (driver.Tx, error) { return recordingTx{c: c.c}, nil }
+
+type recordingTx struct{ c *recordingConnector }
+
+func (tx recordingTx) Commit() error { return tx.c.commitErr }
+func (recordingTx) Rollback() error  { return nil }
+
+type recordingStmt struct {
+	c     *recordingConnector
+	query string
+}
+
+func (s *recordingStmt) Close() error  { return nil }
+func (s *recordingStmt) NumInput() int { return -1 }
+
+func (s *recordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.c.record(s.query, args)
+	if s.c.noneAffected {
+		return driver.RowsAffected(0), nil
+	}
+	return driver.RowsAffected(len(args)), nil
+}
+
+func (s *recordingStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.c.record(s.query, args)
+	return emptyRows{}, nil
+}
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string              { return []string{"event_id"} }
+func (emptyRows) Close() error                   { return nil }
+func (emptyRows) Next(dest []driver.Value) error { return io.EOF }
+
+func newRecordingDB() (*DB, *recordingConnector) {
+	c := &recordingConnector{}
+	return &DB{conn: sql.OpenDB(c)}, c
+}
+
+func TestNewestByKey(t *testing.T) {
+	at := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	user := func(eventID, userID string, sequence int64) models.UserCreated {
+		return models.UserCreated{
+			BaseEvent: models.BaseEvent{EventID: eventID, EventType: models.UserCreatedEvent, Timestamp: at, Sequence: sequence},
+			UserID:    userID,
+		}
+	}
+
+	events := []models.UserCreated{
+		user("evt-1", "user-1", 1),
+		user("evt-2", "user-2", 1),
+		user("evt-3", "user-1", 3),
+		user("evt-4", "user-1", 2),
+	}
+
+	newest, superseded := newestByKey(events, func(e models.UserCreated) (string, models.BaseEvent) { return e.UserID, e.BaseEvent })
+	var ids []string
+	for _, e := range newest {
+		ids = append(ids, e.EventID)
+	}
+	if got := strings.Join(ids, ","); got != "evt-3,evt-2" {
+		t.Errorf("Expected the newest event per user in order of first appearance, got %s", got)
+	}
+	if superseded != 2 {
+		t.Errorf("Expected 2 superseded events, got %d", superseded)
+	}
+}
+
+func TestWriteBatchStaysUnderParameterLimit(t *testing.T) {
+	db, conn := newRecordingDB()
+	defer db.Close()
+
+	const n = 1500
+	batch := &Batch{}
+	for i := 0; i < n; i++ {
+		base := func(kind string) models.BaseEvent {
+			return models.BaseEvent{EventID: fmt.Sprintf("%s-%d", kind, i), Timestamp: time.Now()}
+		}
+		batch.AddUser(models.UserCreated{BaseEvent: base("user"), UserID: fmt.Sprintf("user-%d", i)})
+		batch.AddPayment(models.PaymentSettled{BaseEvent: base("payment"), PaymentID: fmt.Sprintf("payment-%d", i)})
+		batch.AddInventory(models.InventoryAdjusted{BaseEvent: base("inventory"), SKU: fmt.Sprintf("SKU-%d", i), Quantity: 1, AdjustmentType: models.AdjustmentAdd})
+	}
+
+	if err := db.WriteBatch(context.Background(), batch); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, stmt := range conn.matching("") {
+		if len(stmt.args) > sqlServerMaxParams {
+			t.Errorf("Statement has %d parameters, over the limit of %d: %.60s", len(stmt.args), sqlServerMaxParams, strings.TrimSpace(stmt.query))
+		}
+	}
+
+	// Every row is written, split over several statements
+	tests := []struct {
+		table string
+		cols  int
+	}{
+		{"MERGE INTO users", 6},
+		{"MERGE INTO payments", 8},
+		{"MERGE INTO inventory", 2},
+	}
+	for _, tt := range tests {
+		stmts := conn.matching(tt.table)
+		rows := 0
+		for _, stmt := range stmts {
+			rows += (len(stmt.args) - 1) / tt.cols
+		}
+		if rows != n || len(stmts) < 2 {
+			t.Errorf("%s: expected %d rows over several statements, got %d in %d", tt.table, n, rows, len(stmts))
+		}
+	}
+}
+
+func counterValue(c prometheus.Counter) float64 {
+	var m dto.Metric
+	c.Write(&m)
+	return m.GetCounter().GetValue()
+}
+
+func TestWriteBatchCountsDuplicatesOnlyOnCommit(t *testing.T) {
+	db, conn := newRecordingDB()
+	defer db.Close()
+
+	// The same event delivered twice within the batch is a duplicate
+	base := models.BaseEvent{EventID: "evt-dup", EventType: models.UserCreatedEvent, Timestamp: time.Now()}
+	batch := &Batch{}
+	batch.AddUser(models.UserCreated{BaseEvent: base, UserID: "user-1"})
+	batch.AddUser(models.UserCreated{BaseEvent: base, UserID: "user-1"})
+
+	skipped := metrics.DuplicateEventsSkipped.WithLabelValues(string(models.UserCreatedEvent))
+	before := counterValue(skipped)
+
+	// A rolled back batch is retried event by event, which counts them then
+	conn.commitErr = errors.New("connection reset")
+	if err := db.WriteBatch(context.Background(), batch); err == nil {
+		t.Fatal("Expected the commit error")
+	}
+	if got := counterValue(skipped) - before; got != 0 {
+		t.Errorf("Expected no duplicates counted for a rolled back batch, got %v", got)
+	}
+
+	conn.commitErr = nil
+	if err := db.WriteBatch(context.Background(), batch); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := counterValue(skipped) - before; got != 1 {
+		t.Errorf("Expected 1 duplicate counted once the batch commits, got %v", got)
+	}
+}
+
+func TestWriteBatchCountsStaleOnlyOnCommit(t *testing.T) {
+	db, conn := newRecordingDB()
+	defer db.Close()
+	conn.noneAffected = true
+
+	// evt-1 is superseded within the batch and evt-2 by the projection
+	at := time.Now()
+	batch := &Batch{}
+	batch.AddUser(models.UserCreated{BaseEvent: models.BaseEvent{EventID: "evt-1", EventType: models.UserCreatedEvent, Timestamp: at, Sequence: 1}, UserID: "user-1"})
+	batch.AddUser(models.UserCreated{BaseEvent: models.BaseEvent{EventID: "evt-2", EventType: models.UserCreatedEvent, Timestamp: at, Sequence: 2}, UserID: "user-1"})
+
+	stale := metrics.StaleEventsSkipped.WithLabelValues(string(models.UserCreatedEvent))
+	before := counterValue(stale)
+
+	conn.commitErr = errors.New("connection reset")
+	if err := db.WriteBatch(context.Background(), batch); err == nil {
+		t.Fatal("Expected the commit error")
+	}
+	if got := counterValue(stale) - before; got != 0 {
+		t.Errorf("Expected no stale events counted for a rolled back batch, got %v", got)
+	}
+
+	conn.commitErr = nil
+	if err := db.WriteBatch(context.Background(), batch); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := counterValue(stale) - before; got != 2 {
+		t.Errorf("Expected 2 stale events counted once the batch commits, got %v", got)
+	}
+}
@@ -52,7 +52,7 @@ func (db *DB) Close() error {
 	return db.conn.Close()
 }
 
-// UpsertUser inserts or updates a user (idempotent)
+// UpsertUser inserts or updates a user exactly once per event
 func (db *DB) UpsertUser(ctx context.Context, event models.UserCreated) error {
 	start := time.Now()
 	defer func() {
@@ -63,21 +63,30 @@ func (db *DB) UpsertUser(ctx context.Context, event models.UserCreated) error {
 		MERGE INTO users AS target
 		USING (SELECT @p1 AS user_id) AS source
 		ON target.user_id = source.user_id
-		WHEN MATCHED THEN
-			UPDATE SET email = @p2, first_name = @p3, last_name = @p4, updated_at = @p5
+		WHEN MATCHED AND target.version < @p7 THEN
+			UPDATE SET email = @p2, first_name = @p3, last_name = @p4, updated_at = @p5, version = @p7
 		WHEN NOT MATCHED THEN
-			INSERT (user_id, email, first_name, last_name, created_at, updated_at)
-			VALUES (@p1, @p2, @p3, @p4, @p6, @p5);
+			INSERT (user_id, email, first_name, last_name, created_at, updated_at, version)
+			VALUES (@p1, @p2, @p3, @p4, @p6, @p5, @p7);
 	`
 
-	_, err := db.conn.ExecContext(ctx, query,
-		event.UserID,
-		event.Email,
-		event.FirstName,
-		event.LastName,
-		time.Now(),
-		event.CreatedAt,
-	)
+	var stale int
+	applied, err := db.applyOnce(ctx, event.BaseEvent, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, query,
+			event.UserID,
+			event.Email,
+			event.FirstName,
+			event.LastName,
+			time.Now(),
+			event.CreatedAt,
+			event.Version(),
+		)
+		if err != nil {
+			return err
+		}
+		stale, err = countStale(res, 1)
+		return err
+	})
 
 	if err != nil {
 		logger.WithEventID(event.EventID).WithFields(logrus.Fields{
@@ -86,6 +95,16 @@ func (db *DB) UpsertUser(ctx context.Context, event models.UserCreated) error {
 		}).Error("Failed to upsert user")
 		return fmt.Errorf("failed to upsert user: %w", err)
 	}
+	if !applied {
+		return nil
+	}
+	if stale > 0 {
+		recordStale(event.EventType, stale)
+		logger.WithEventID(event.EventID).WithFields(logrus.Fields{
+			"userId": event.UserID,
+		}).Info("Stale user event ignored")
+		return nil
+	}
 
 	logger.WithEventID(event.EventID).WithFields(logrus.Fields{
 		"userId": event.UserID,
@@ -94,50 +113,82 @@ func (db *DB) UpsertUser(ctx context.Context, event models.UserCreated) error {
 	return nil
 }
 
-// UpsertOrder inserts or updates an order (idempotent)
+// UpsertOrder inserts or updates an order exactly once per event
 func (db *DB) UpsertOrder(ctx context.Context, event models.OrderPlaced) error {
 	start := time.Now()
 	defer func() {
 		metrics.DBLatency.WithLabelValues("upsert_order").Observe(time.Since(start).Seconds())
 	}()
 
-	tx, err := db.conn.BeginTx(ctx, nil)
+	var current bool
+	applied, err := db.applyOnce(ctx, event.BaseEvent, func(tx *sql.Tx) (err error) {
+		current, err = upsertOrderTx(ctx, tx, event)
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return err
+	}
+	if !applied {
+		return nil
 	}
-	defer tx.Rollback()
+	if !current {
+		recordStale(event.EventType, 1)
+		return nil
+	}
+
+	logger.WithEventID(event.EventID).WithFields(logrus.Fields{
+		"orderId": event.OrderID,
+	}).Info("Order upserted successfully")
+
+	return nil
+}
 
+// upsertOrderTx writes an order and replaces its items within tx. It returns
+// false without touching the order if it already holds a newer version.
+func upsertOrderTx(ctx context.Context, tx *sql.Tx, event models.OrderPlaced) (bool, error) {
 	// Upsert order
 	orderQuery := `
 		MERGE INTO orders AS target
 		USING (SELECT @p1 AS order_id) AS source
 		ON target.order_id = source.order_id
-		WHEN MATCHED THEN
-			UPDATE SET user_id = @p2, total_amount = @p3, currency = @p4, updated_at = @p5
+		WHEN MATCHED AND target.version < @p7 THEN
+			UPDATE SET user_id = @p2, total_amount = @p3, currency = @p4, updated_at = @p5, version = @p7
 		WHEN NOT MATCHED THEN
-			INSERT (order_id, user_id, total_amount, currency, placed_at, updated_at)
-			VALUES (@p1, @p2, @p3, @p4, @p6, @p5);
+			INSERT (order_id, user_id, total_amount, currency, placed_at, updated_at, version)
+			VALUES (@p1, @p2, @p3, @p4, @p6, @p5, @p7);
 	`
 
-	_, err = tx.ExecContext(ctx, orderQuery,
+	res, err := tx.ExecContext(ctx, orderQuery,
 		event.OrderID,
 		event.UserID,
 		event.TotalAmount,
 		event.Currency,
 		time.Now(),
 		event.PlacedAt,
+		event.Version(),
 	)
 
 	if err != nil {
 		logger.WithEventID(event.EventID).Error("Failed to upsert order")
-		return fmt.Errorf("failed to upsert order: %w", err)
+		return false, fmt.Errorf("failed to upsert order: %w", missingParent(err, UserParent(event.UserID)))
+	}
+
+	stale, err := countStale(res, 1)
+	if err != nil {
+		return false, err
+	}
+	if stale > 0 {
+		logger.WithEventID(event.EventID).WithFields(logrus.Fields{
+			"orderId": event.OrderID,
+		}).Info("Stale order event ignored")
+		return false, nil
 	}
 
 	// Delete existing order items
 	deleteQuery := `DELETE FROM order_items WHERE order_id = @p1`
 	_, err = tx.ExecContext(ctx, deleteQuery, event.OrderID)
 	if err != nil {
-		return fmt.Errorf("failed to delete existing order items: %w", err)
+		return false, fmt.Errorf("failed to delete existing order items: %w", err)
 	}
 
 	// Insert order items
@@ -154,22 +205,14 @@ func (db *DB) UpsertOrder(ctx context.Context, event models.OrderPlaced) error {
 			item.Price,
 		)
 		if err != nil {
-			return fmt.Errorf("failed to insert order item: %w", err)
+			return false, fmt.Errorf("failed to insert order item: %w", err)
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
-	logger.WithEventID(event.EventID).WithFields(logrus.Fields{
-		"orderId": event.OrderID,
-	}).Info("Order upserted successfully")
-
-	return nil
+	return true, nil
 }
 
-// UpsertPayment inserts or updates a payment (idempotent)
+// UpsertPayment inserts or updates a payment exactly once per event
 func (db *DB) UpsertPayment(ctx context.Context, event models.PaymentSettled) error {
 	start := time.Now()
 	defer func() {
@@ -180,29 +223,48 @@ func (db *DB) UpsertPayment(ctx context.Context, event models.PaymentSettled) er
 		MERGE INTO payments AS target
 		USING (SELECT @p1 AS payment_id) AS source
 		ON target.payment_id = source.payment_id
-		WHEN MATCHED THEN
+		WHEN MATCHED AND target.version < @p9 THEN
 			UPDATE SET order_id = @p2, amount = @p3, currency = @p4, 
-			           payment_method = @p5, status = @p6, settled_at = @p7, updated_at = @p8
+			           payment_method = @p5, status = @p6, settled_at = @p7, updated_at = @p8, version = @p9
 		WHEN NOT MATCHED THEN
-			INSERT (payment_id, order_id, amount, currency, payment_method, status, settled_at, updated_at)
-			VALUES (@p1, @p2, @p3, @p4, @p5, @p6, @p7, @p8);
+			INSERT (payment_id, order_id, amount, currency, payment_method, status, settled_at, updated_at, version)
+			VALUES (@p1, @p2, @p3, @p4, @p5, @p6, @p7, @p8, @p9);
 	`
 
-	_, err := db.conn.ExecContext(ctx, query,
-		event.PaymentID,
-		event.OrderID,
-		event.Amount,
-		event.Currency,
-		event.PaymentMethod,
-		event.Status,
-		event.SettledAt,
-		time.Now(),
-	)
+	var stale int
+	applied, err := db.applyOnce(ctx, event.BaseEvent, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, query,
+			event.PaymentID,
+			event.OrderID,
+			event.Amount,
+			event.Currency,
+			event.PaymentMethod,
+			event.Status,
+			event.SettledAt,
+			time.Now(),
+			event.Version(),
+		)
+		if err != nil {
+			return missingParent(err, OrderParent(event.OrderID))
+		}
+		stale, err = countStale(res, 1)
+		return err
+	})
 
 	if err != nil {
 		logger.WithEventID(event.EventID).Error("Failed to upsert payment")
 		return fmt.Errorf("failed to upsert payment: %w", err)
 	}
+	if !applied {
+		return nil
+	}
+	if stale > 0 {
+		recordStale(event.EventType, stale)
+		logger.WithEventID(event.EventID).WithFields(logrus.Fields{
+			"paymentId": event.PaymentID,
+		}).Info("Stale payment event ignored")
+		return nil
+	}
 
 	logger.WithEventID(event.EventID).WithFields(logrus.Fields{
 		"paymentId": event.PaymentID,
@@ -211,18 +273,14 @@ func (db *DB) UpsertPayment(ctx context.Context, event models.PaymentSettled) er
 	return nil
 }
 
-// UpsertInventory adjusts inventory (idempotent via unique constraint)
+// UpsertInventory adjusts inventory exactly once per event
 func (db *DB) UpsertInventory(ctx context.Context, event models.InventoryAdjusted) error {
 	start := time.Now()
 	defer func() {
 		metrics.DBLatency.WithLabelValues("upsert_inventory").Observe(time.Since(start).Seconds())
 	}()
 
-	// Calculate quantity delta
-	delta := event.Quantity
-	if event.AdjustmentType == "subtract" {
-		delta = -delta
-	}
+	delta := inventoryDelta(event)
 
 	query := `
 		MERGE INTO inventory AS target
@@ -235,16 +293,22 @@ func (db *DB) UpsertInventory(ctx context.Context, event models.InventoryAdjuste
 			VALUES (@p1, @p2, @p3);
 	`
 
-	_, err := db.conn.ExecContext(ctx, query,
-		event.SKU,
-		delta,
-		time.Now(),
-	)
+	applied, err := db.applyOnce(ctx, event.BaseEvent, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, query,
+			event.SKU,
+			delta,
+			time.Now(),
+		)
+		return err
+	})
 
 	if err != nil {
 		logger.WithEventID(event.EventID).Error("Failed to upsert inventory")
 		return fmt.Errorf("failed to upsert inventory: %w", err)
 	}
+	if !applied {
+		return nil
+	}
 
 	logger.WithEventID(event.EventID).WithFields(logrus.Fields{
 		"sku":   event.SKU,
@@ -254,6 +318,33 @@ func (db *DB) UpsertInventory(ctx context.Context, event models.InventoryAdjuste
 	return nil
 }
 
+// inventoryDelta returns the signed quantity change of an adjustment
+func inventoryDelta(event models.InventoryAdjusted) int {
+	if event.AdjustmentType == models.AdjustmentSubtract {
+		return -event.Quantity
+	}
+	return event.Quantity
+}
+
+// countStale returns how many of the rows given to a versioned MERGE were
+// not applied because the projection already held a newer version
+func countStale(res sql.Result, rows int) (int, error) {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read rows affected: %w", err)
+	}
+	return rows - int(affected), nil
+}
+
+// recordStale records stale events in the stale events metric. Callers
+// record them only once their transaction commits, so a write that rolls
+// back and is retried does not count them twice.
+func recordStale(eventType models.EventType, n int) {
+	if n > 0 {
+		metrics.StaleEventsSkipped.WithLabelValues(string(eventType)).Add(float64(n))
+	}
+}
+
 // GetUserWithOrders retrieves a user with their last 5 orders
 func (db *DB) GetUserWithOrders(ctx context.Context, userID string) (*UserWithOrders, error) {
 	start := time.Now()
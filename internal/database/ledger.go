@@ -0,0 +1,175 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"event-pipeline/internal/logger"
+	"event-pipeline/internal/metrics"
+	"event-pipeline/internal/models"
+)
+
+// applyOnce runs apply in a transaction that also records the event in the
+// processed_events ledger. If the event is already in the ledger, apply is
+// skipped and applied is false. Events without an ID cannot be tracked and
+// are always applied.
+func (db *DB) applyOnce(ctx context.Context, base models.BaseEvent, apply func(tx *sql.Tx) error) (applied bool, err error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if base.EventID != "" {
+		claimed, err := claimEventTx(ctx, tx, base)
+		if err != nil {
+			return false, err
+		}
+		if !claimed {
+			recordDuplicates([]models.BaseEvent{base})
+			return false, nil
+		}
+	}
+
+	if err := apply(tx); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+// claimEventTx records an event in the ledger, returning false if it was
+// already there. The range lock holds until tx ends so concurrent deliveries
+// of the same event serialise on it.
+func claimEventTx(ctx context.Context, tx *sql.Tx, base models.BaseEvent) (bool, error) {
+	query := `
+		INSERT INTO processed_events (event_id, event_type, processed_at)
+		SELECT @p1, @p2, @p3
+		WHERE NOT EXISTS (
+			SELECT 1 FROM processed_events WITH (UPDLOCK, HOLDLOCK) WHERE event_id = @p1
+		);
+	`
+
+	res, err := tx.ExecContext(ctx, query, base.EventID, string(base.EventType), time.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to record processed event: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to record processed event: %w", err)
+	}
+	return n == 1, nil
+}
+
+// claimEventsTx records a set of events in the ledger and returns the IDs
+// that were not processed before. An ID repeated within the set is claimed
+// once, by its first occurrence.
+func claimEventsTx(ctx context.Context, tx *sql.Tx, bases []models.BaseEvent) (map[string]bool, error) {
+	claimed := make(map[string]bool, len(bases))
+	seen := make(map[string]bool, len(bases))
+	var fresh []models.BaseEvent
+
+	var ids []string
+	for _, b := range bases {
+		if b.EventID == "" || seen[b.EventID] {
+			continue
+		}
+		seen[b.EventID] = true
+		ids = append(ids, b.EventID)
+	}
+
+	existing := make(map[string]bool)
+	for start := 0; start < len(ids); start += maxParams {
+		end := min(start+maxParams, len(ids))
+
+		args := make([]interface{}, 0, end-start)
+		for _, id := range ids[start:end] {
+			args = append(args, id)
+		}
+
+		query := fmt.Sprintf(`
+			SELECT event_id FROM processed_events WITH (UPDLOCK, HOLDLOCK)
+			WHERE event_id IN %s
+		`, placeholders(0, len(args)))
+
+		rows, err := tx.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check processed events: %w", err)
+		}
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan processed event: %w", err)
+			}
+			existing[id] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to check processed events: %w", err)
+		}
+	}
+
+	for _, b := range bases {
+		if b.EventID == "" || existing[b.EventID] || claimed[b.EventID] {
+			continue
+		}
+		claimed[b.EventID] = true
+		fresh = append(fresh, b)
+	}
+
+	const cols = 3
+	now := time.Now()
+	for start := 0; start < len(fresh); start += maxParams / cols {
+		end := min(start+maxParams/cols, len(fresh))
+
+		args := make([]interface{}, 0, (end-start)*cols)
+		values := make([]string, 0, end-start)
+		for _, b := range fresh[start:end] {
+			values = append(values, placeholders(len(args), cols))
+			args = append(args, b.EventID, string(b.EventType), now)
+		}
+
+		query := `INSERT INTO processed_events (event_id, event_type, processed_at) VALUES ` + strings.Join(values, ", ")
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return nil, fmt.Errorf("failed to record processed events: %w", err)
+		}
+	}
+
+	return claimed, nil
+}
+
+// claimedOnly filters events down to those claimed by this transaction and
+// returns the rest as duplicates. Duplicates are not recorded here; the
+// caller records them once the transaction commits, so a batch that rolls
+// back and is retried event by event does not count them twice.
+func claimedOnly[E any](events []E, base func(E) models.BaseEvent, claimed, used map[string]bool) (kept []E, duplicates []models.BaseEvent) {
+	for _, e := range events {
+		b := base(e)
+		if b.EventID != "" {
+			if !claimed[b.EventID] || used[b.EventID] {
+				duplicates = append(duplicates, b)
+				continue
+			}
+			used[b.EventID] = true
+		}
+		kept = append(kept, e)
+	}
+	return kept, duplicates
+}
+
+// recordDuplicates counts and logs events skipped because they were already
+// processed
+func recordDuplicates(bases []models.BaseEvent) {
+	for _, b := range bases {
+		metrics.DuplicateEventsSkipped.WithLabelValues(string(b.EventType)).Inc()
+		logger.WithEventID(b.EventID).Info("Event already processed, skipping")
+	}
+}
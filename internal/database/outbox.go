@@ -0,0 +1,332 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"event-pipeline/internal/logger"
+	"event-pipeline/internal/metrics"
+	"event-pipeline/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxOutboxError bounds the error text stored on an outbox row
+const maxOutboxError = 1000
+
+// OutboxMessage is an event waiting in the outbox to be published
+type OutboxMessage struct {
+	ID        int64
+	EventID   string
+	EventType models.EventType
+	Key       string
+	Payload   []byte
+	CreatedAt time.Time
+
+	// Failed publishes so far
+	Attempts int
+}
+
+// outboxRef is an unsent outbox row seen when checking the order of a batch
+type outboxRef struct {
+	ID  int64
+	Key string
+}
+
+// WriteWithOutbox runs apply and adds events to the outbox in a single
+// transaction, so the events are published if and only if the write
+// commits. apply may be nil to only add events. Each event is stamped with
+// its eventType and, when missing, an eventId and timestamp.
+func (db *DB) WriteWithOutbox(ctx context.Context, apply func(tx *sql.Tx) error, events ...models.Publishable) error {
+	start := time.Now()
+	defer func() {
+		metrics.DBLatency.WithLabelValues("write_outbox").Observe(time.Since(start).Seconds())
+	}()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if apply != nil {
+		if err := apply(tx); err != nil {
+			return err
+		}
+	}
+
+	for _, event := range events {
+		if err := addToOutboxTx(ctx, tx, event); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// addToOutboxTx stores an event as an unsent outbox row
+func addToOutboxTx(ctx context.Context, tx *sql.Tx, event models.Publishable) error {
+	models.Stamp(event)
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	query := `
+		INSERT INTO outbox (event_id, event_type, message_key, payload, created_at)
+		VALUES (@p1, @p2, @p3, @p4, @p5);
+	`
+
+	if _, err := tx.ExecContext(ctx, query,
+		event.GetEventID(),
+		string(event.GetEventType()),
+		event.GetKey(),
+		string(payload),
+		time.Now(),
+	); err != nil {
+		return fmt.Errorf("failed to add event to outbox: %w", err)
+	}
+	return nil
+}
+
+// RelayOutbox claims up to limit unsent outbox messages and passes them to
+// publish in ID order, marking each one sent once publish returns.
+//
+// Several relays can run at once. Rows locked by another relay are skipped
+// (READPAST) rather than waited for, and so is every later message with the
+// same key, so messages of one key are still published in order. Claimed
+// rows stay locked until the batch is done.
+//
+// A failed publish is recorded on its row and holds up the rest of its key
+// until the next poll, while other keys carry on. After maxAttempts failures
+// the row is parked by setting dead_at, so later messages with its key are
+// published again. Messages published by a relay that dies before marking
+// them sent are published again, so consumers must tolerate duplicates.
+func (db *DB) RelayOutbox(ctx context.Context, limit, maxAttempts int, publish func(OutboxMessage) error) (sent int, err error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	claimed, err := claimOutboxTx(ctx, tx, limit)
+	if err != nil || len(claimed) == 0 {
+		return 0, err
+	}
+
+	unsent, err := unsentOutboxTx(ctx, tx, claimed)
+	if err != nil {
+		return 0, err
+	}
+
+	ids, failures := relayBatch(publishable(claimed, unsent), maxAttempts, publish)
+
+	var publishErrs []error
+	for _, f := range failures {
+		if err := markOutboxFailedTx(ctx, tx, f); err != nil {
+			return 0, err
+		}
+		publishErrs = append(publishErrs, fmt.Errorf("failed to publish outbox message %d: %w", f.Message.ID, f.Err))
+	}
+
+	if err := markOutboxSentTx(ctx, tx, ids); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	for _, f := range failures {
+		if f.Dead {
+			metrics.OutboxRelayed.WithLabelValues(string(f.Message.EventType), "dead").Inc()
+			logger.WithEventID(f.Message.EventID).WithFields(logrus.Fields{
+				"outboxId": f.Message.ID,
+				"attempts": f.Message.Attempts + 1,
+				"error":    f.Err.Error(),
+			}).Error("Outbox message parked after repeated publish failures")
+		}
+	}
+	return len(ids), errors.Join(publishErrs...)
+}
+
+// outboxFailure is a failed publish of an outbox message. Dead messages
+// have used up their attempts and are parked.
+type outboxFailure struct {
+	Message OutboxMessage
+	Err     error
+	Dead    bool
+}
+
+// relayBatch publishes messages in order and returns the IDs of those sent.
+// A failed message holds up the later messages with its key, which are
+// neither published nor counted as failed.
+func relayBatch(messages []OutboxMessage, maxAttempts int, publish func(OutboxMessage) error) ([]int64, []outboxFailure) {
+	var sent []int64
+	var failures []outboxFailure
+	blocked := make(map[string]bool)
+	for _, m := range messages {
+		if blocked[m.Key] {
+			continue
+		}
+		if err := publish(m); err != nil {
+			blocked[m.Key] = true
+			failures = append(failures, outboxFailure{Message: m, Err: err, Dead: m.Attempts+1 >= maxAttempts})
+			continue
+		}
+		sent = append(sent, m.ID)
+	}
+	return sent, failures
+}
+
+// claimOutboxTx locks the oldest unsent rows not locked by anyone else
+func claimOutboxTx(ctx context.Context, tx *sql.Tx, limit int) ([]OutboxMessage, error) {
+	start := time.Now()
+	defer func() {
+		metrics.DBLatency.WithLabelValues("claim_outbox").Observe(time.Since(start).Seconds())
+	}()
+
+	query := `
+		SELECT TOP (@p1) id, event_id, event_type, message_key, payload, created_at, attempts
+		FROM outbox WITH (UPDLOCK, READPAST, ROWLOCK)
+		WHERE sent_at IS NULL AND dead_at IS NULL
+		ORDER BY id;
+	`
+
+	rows, err := tx.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox messages: %w", err)
+	}
+	defer rows.Close()
+
+	var claimed []OutboxMessage
+	for rows.Next() {
+		var m OutboxMessage
+		var eventType, payload string
+		if err := rows.Scan(&m.ID, &m.EventID, &eventType, &m.Key, &payload, &m.CreatedAt, &m.Attempts); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox message: %w", err)
+		}
+		m.EventType = models.EventType(eventType)
+		m.Payload = []byte(payload)
+		claimed = append(claimed, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to claim outbox messages: %w", err)
+	}
+	return claimed, nil
+}
+
+// unsentOutboxTx lists the unsent rows with the keys of the claimed
+// messages, up to the last claimed ID. It reads uncommitted data so rows
+// locked by other relays, or inserted by writers yet to commit, are seen.
+func unsentOutboxTx(ctx context.Context, tx *sql.Tx, claimed []OutboxMessage) ([]outboxRef, error) {
+	seen := make(map[string]bool)
+	args := []interface{}{claimed[len(claimed)-1].ID}
+	for _, m := range claimed {
+		if !seen[m.Key] {
+			seen[m.Key] = true
+			args = append(args, m.Key)
+		}
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, message_key FROM outbox WITH (READUNCOMMITTED)
+		WHERE sent_at IS NULL AND dead_at IS NULL AND id <= @p1 AND message_key IN %s
+		ORDER BY id
+	`, placeholders(1, len(args)-1))
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check outbox order: %w", err)
+	}
+	defer rows.Close()
+
+	var unsent []outboxRef
+	for rows.Next() {
+		var r outboxRef
+		if err := rows.Scan(&r.ID, &r.Key); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox message: %w", err)
+		}
+		unsent = append(unsent, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to check outbox order: %w", err)
+	}
+	return unsent, nil
+}
+
+// publishable keeps the claimed messages that can be published without
+// overtaking an earlier unsent message of the same key that was not
+// claimed, i.e. one held by another relay or a writer. unsent must be in ID
+// order and include every claimed message.
+func publishable(claimed []OutboxMessage, unsent []outboxRef) []OutboxMessage {
+	mine := make(map[int64]bool, len(claimed))
+	for _, m := range claimed {
+		mine[m.ID] = true
+	}
+
+	blocked := make(map[string]bool)
+	ready := make(map[int64]bool, len(claimed))
+	for _, r := range unsent {
+		if !mine[r.ID] {
+			blocked[r.Key] = true
+			continue
+		}
+		ready[r.ID] = !blocked[r.Key]
+	}
+
+	var out []OutboxMessage
+	for _, m := range claimed {
+		if ready[m.ID] {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// markOutboxSentTx records when the given rows were published
+func markOutboxSentTx(ctx context.Context, tx *sql.Tx, ids []int64) error {
+	now := time.Now()
+	for start := 0; start < len(ids); start += maxParams {
+		end := min(start+maxParams, len(ids))
+
+		args := []interface{}{now}
+		for _, id := range ids[start:end] {
+			args = append(args, id)
+		}
+
+		query := fmt.Sprintf(`UPDATE outbox SET sent_at = @p1 WHERE id IN %s`, placeholders(1, end-start))
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return fmt.Errorf("failed to mark outbox messages sent: %w", err)
+		}
+	}
+	return nil
+}
+
+// markOutboxFailedTx records a failed publish on a row, which is retried on
+// the next poll unless it is dead
+func markOutboxFailedTx(ctx context.Context, tx *sql.Tx, f outboxFailure) error {
+	msg := f.Err.Error()
+	if len(msg) > maxOutboxError {
+		msg = strings.ToValidUTF8(msg[:maxOutboxError], "")
+	}
+
+	var deadAt interface{}
+	if f.Dead {
+		deadAt = time.Now()
+	}
+
+	query := `UPDATE outbox SET attempts = attempts + 1, last_error = @p2, dead_at = @p3 WHERE id = @p1`
+	if _, err := tx.ExecContext(ctx, query, f.Message.ID, msg, deadAt); err != nil {
+		return fmt.Errorf("failed to record outbox failure: %w", err)
+	}
+	return nil
+}
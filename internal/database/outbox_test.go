@@ -0,0 +1,74 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPublishableKeepsKeyOrder(t *testing.T) {
+	claimed := []OutboxMessage{
+		{ID: 1, Key: "order-1"},
+		{ID: 2, Key: "order-2"},
+		{ID: 4, Key: "order-1"},
+		{ID: 6, Key: "order-2"},
+		{ID: 7, Key: "order-3"},
+	}
+
+	// Row 3 of order-1 and row 5 of order-3 are held by another relay
+	unsent := []outboxRef{
+		{ID: 1, Key: "order-1"},
+		{ID: 2, Key: "order-2"},
+		{ID: 3, Key: "order-1"},
+		{ID: 4, Key: "order-1"},
+		{ID: 5, Key: "order-3"},
+		{ID: 6, Key: "order-2"},
+		{ID: 7, Key: "order-3"},
+	}
+
+	var ids []int64
+	for _, m := range publishable(claimed, unsent) {
+		ids = append(ids, m.ID)
+	}
+
+	want := []int64{1, 2, 6}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("Expected %v, got %v", want, ids)
+	}
+}
+
+func TestRelayBatchBlocksOnlyFailedKeys(t *testing.T) {
+	messages := []OutboxMessage{
+		{ID: 1, Key: "order-1", Attempts: 0},
+		{ID: 2, Key: "order-2"},
+		{ID: 3, Key: "order-1"},
+		{ID: 4, Key: "order-3", Attempts: 2},
+		{ID: 5, Key: "order-2"},
+	}
+
+	// Rows 1 and 4 can never be published, e.g. because they are too large
+	tooLarge := errors.New("message too large")
+	var published []int64
+	sent, failures := relayBatch(messages, 3, func(m OutboxMessage) error {
+		if m.ID == 1 || m.ID == 4 {
+			return tooLarge
+		}
+		published = append(published, m.ID)
+		return nil
+	})
+
+	want := []int64{2, 5}
+	if !reflect.DeepEqual(sent, want) || !reflect.DeepEqual(published, want) {
+		t.Errorf("Expected %v to be sent, got %v (published %v)", want, sent, published)
+	}
+
+	if len(failures) != 2 {
+		t.Fatalf("Expected 2 failures, got %+v", failures)
+	}
+	if f := failures[0]; f.Message.ID != 1 || f.Dead || !errors.Is(f.Err, tooLarge) {
+		t.Errorf("Expected row 1 to fail and be retried, got %+v", f)
+	}
+	if f := failures[1]; f.Message.ID != 4 || !f.Dead {
+		t.Errorf("Expected row 4 to be parked on its last attempt, got %+v", f)
+	}
+}
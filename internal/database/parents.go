@@ -0,0 +1,56 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+)
+
+// errConstraintViolation is the SQL Server error number for a violated
+// FOREIGN KEY or CHECK constraint
+const errConstraintViolation = 547
+
+// Parent identifies a row that other projections reference by foreign key
+type Parent struct {
+	Table string
+	ID    string
+}
+
+// UserParent returns the parent reference of a user row
+func UserParent(userID string) Parent { return Parent{Table: "users", ID: userID} }
+
+// OrderParent returns the parent reference of an order row
+func OrderParent(orderID string) Parent { return Parent{Table: "orders", ID: orderID} }
+
+// String returns the parent as "table:id"
+func (p Parent) String() string {
+	return p.Table + ":" + p.ID
+}
+
+// MissingParentError reports a write rejected because the row it references
+// has not been written yet
+type MissingParentError struct {
+	Parent Parent
+	Err    error
+}
+
+func (e *MissingParentError) Error() string {
+	return fmt.Sprintf("missing parent %s: %v", e.Parent, e.Err)
+}
+
+func (e *MissingParentError) Unwrap() error {
+	return e.Err
+}
+
+// missingParent wraps err in a MissingParentError for parent when it is a
+// foreign key violation, and returns it unchanged otherwise
+func missingParent(err error, parent Parent) error {
+	var sqlErr mssql.Error
+	if errors.As(err, &sqlErr) && sqlErr.SQLErrorNumber() == errConstraintViolation &&
+		strings.Contains(sqlErr.Message, "FOREIGN KEY") {
+		return &MissingParentError{Parent: parent, Err: err}
+	}
+	return err
+}
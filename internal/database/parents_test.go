@@ -0,0 +1,44 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	mssql "github.com/denisenkom/go-mssqldb"
+)
+
+func TestMissingParent(t *testing.T) {
+	parent := OrderParent("order-1")
+	tests := []struct {
+		name    string
+		err     error
+		missing bool
+	}{
+		{"foreign key violation", mssql.Error{Number: 547, Message: `The INSERT statement conflicted with the FOREIGN KEY constraint "FK_payments_orders".`}, true},
+		{"wrapped foreign key violation", fmt.Errorf("failed to upsert payment: %w", mssql.Error{Number: 547, Message: "conflicted with the FOREIGN KEY constraint"}), true},
+		{"check violation", mssql.Error{Number: 547, Message: `The INSERT statement conflicted with the CHECK constraint "CK_payments_amount".`}, false},
+		{"other SQL error", mssql.Error{Number: 2627, Message: "Violation of PRIMARY KEY constraint"}, false},
+		{"not a SQL error", errors.New("connection reset"), false},
+	}
+
+	for _, tt := range tests {
+		err := missingParent(tt.err, parent)
+
+		var missing *MissingParentError
+		if got := errors.As(err, &missing); got != tt.missing {
+			t.Errorf("%s: expected missing parent %v, got %v", tt.name, tt.missing, got)
+			continue
+		}
+		// mssql.Error holds a slice, so errors are compared by message
+		if !tt.missing {
+			if err.Error() != tt.err.Error() {
+				t.Errorf("%s: expected the error unchanged, got %v", tt.name, err)
+			}
+			continue
+		}
+		if missing.Parent != parent || missing.Err.Error() != tt.err.Error() {
+			t.Errorf("%s: expected %s wrapping the original error, got %v", tt.name, parent, err)
+		}
+	}
+}
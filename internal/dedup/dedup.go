@@ -0,0 +1,109 @@
+package dedup
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"event-pipeline/internal/config"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Status is the outcome of claiming an event ID
+type Status int
+
+const (
+	// Claimed means the ID was not seen before and is now held by the caller
+	Claimed Status = iota
+	// Duplicate means an event with the ID was processed within the window
+	Duplicate
+	// InProgress means another delivery of the event holds the ID
+	InProgress
+)
+
+// Values stored under an event ID
+const (
+	pendingValue = "pending"
+	doneValue    = "done"
+)
+
+// Filter remembers recently processed event IDs in Redis so redelivered
+// duplicates can be skipped. An ID is claimed with SET NX while its event is
+// processed, and kept for a window once it succeeds.
+type Filter struct {
+	client *redis.Client
+	prefix string
+}
+
+// New creates a new duplicate filter
+func New(cfg *config.RedisConfig) (*Filter, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.GetRedisAddr(),
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+
+	// Test connection
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	return &Filter{
+		client: client,
+		prefix: cfg.DedupKey,
+	}, nil
+}
+
+// Close closes the Redis connection
+func (f *Filter) Close() error {
+	return f.client.Close()
+}
+
+func (f *Filter) key(eventID string) string {
+	return f.prefix + ":" + eventID
+}
+
+// Claim holds an event ID for up to hold while its event is processed, unless
+// the ID is already held or was processed recently
+func (f *Filter) Claim(ctx context.Context, eventID string, hold time.Duration) (Status, error) {
+	ok, err := f.client.SetNX(ctx, f.key(eventID), pendingValue, hold).Result()
+	if err != nil {
+		return Claimed, fmt.Errorf("failed to claim event ID: %w", err)
+	}
+	if ok {
+		return Claimed, nil
+	}
+
+	value, err := f.client.Get(ctx, f.key(eventID)).Result()
+	switch {
+	case err == redis.Nil:
+		// Expired in between; let the caller try again later
+		return InProgress, nil
+	case err != nil:
+		return Claimed, fmt.Errorf("failed to read event ID: %w", err)
+	case value == doneValue:
+		return Duplicate, nil
+	default:
+		return InProgress, nil
+	}
+}
+
+// Complete records a claimed event as processed for the window ttl
+func (f *Filter) Complete(ctx context.Context, eventID string, ttl time.Duration) error {
+	if err := f.client.Set(ctx, f.key(eventID), doneValue, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to record event ID: %w", err)
+	}
+	return nil
+}
+
+// Release gives up a claim so the event can be processed again
+func (f *Filter) Release(ctx context.Context, eventID string) error {
+	if err := f.client.Del(ctx, f.key(eventID)).Err(); err != nil {
+		return fmt.Errorf("failed to release event ID: %w", err)
+	}
+	return nil
+}
@@ -49,16 +49,30 @@ func (d *DLQ) Close() error {
 	return d.client.Close()
 }
 
-// Push adds a failed message to the DLQ
-func (d *DLQ) Push(ctx context.Context, eventID, originalData, errorMsg string) error {
-	entry := models.DLQEntry{
+// Push adds a failed message to the DLQ along with the number of attempts made
+func (d *DLQ) Push(ctx context.Context, eventID, originalData, errorMsg string, retryCount int) error {
+	return d.push(ctx, models.DLQEntry{
 		EventID:      eventID,
 		OriginalData: originalData,
 		Error:        errorMsg,
 		Timestamp:    time.Now(),
-		RetryCount:   0,
-	}
+		RetryCount:   retryCount,
+	})
+}
+
+// PushInvalid adds a message that failed validation to the DLQ, listing the
+// invalid fields
+func (d *DLQ) PushInvalid(ctx context.Context, eventID, originalData string, verr *models.ValidationError) error {
+	return d.push(ctx, models.DLQEntry{
+		EventID:      eventID,
+		OriginalData: originalData,
+		Error:        verr.Error(),
+		Timestamp:    time.Now(),
+		Fields:       verr.Fields,
+	})
+}
 
+func (d *DLQ) push(ctx context.Context, entry models.DLQEntry) error {
 	data, err := json.Marshal(entry)
 	if err != nil {
 		return fmt.Errorf("failed to marshal DLQ entry: %w", err)
@@ -72,8 +86,9 @@ func (d *DLQ) Push(ctx context.Context, eventID, originalData, errorMsg string)
 	// Increment DLQ counter
 	metrics.DLQCount.Inc()
 
-	logger.WithEventID(eventID).WithFields(logrus.Fields{
-		"error": errorMsg,
+	logger.WithEventID(entry.EventID).WithFields(logrus.Fields{
+		"error":      entry.Error,
+		"retryCount": entry.RetryCount,
 	}).Warn("Message pushed to DLQ")
 
 	return nil
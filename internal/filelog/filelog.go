@@ -0,0 +1,50 @@
+package filelog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// Ext is the extension of the files holding events
+const Ext = ".ndjson"
+
+// Record is one line of an event file. The event itself is stored as
+// plain JSON in Value so files stay readable and easy to write by hand.
+type Record struct {
+	Key       string            `json:"key,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Value     json.RawMessage   `json:"value"`
+}
+
+// Path returns the file events of a topic are appended to
+func Path(dir, topic string) string {
+	return filepath.Join(dir, topic+Ext)
+}
+
+// Encode renders a record as a single line, including the newline
+func Encode(r Record) ([]byte, error) {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode record: %w", err)
+	}
+	return append(line, '\n'), nil
+}
+
+// Decode parses a line. A line without a "value" field is taken to be a
+// bare event, so files of events can be dropped in as they are.
+func Decode(line []byte) (Record, error) {
+	line = bytes.TrimSpace(line)
+
+	var r Record
+	if err := json.Unmarshal(line, &r); err != nil {
+		return Record{}, fmt.Errorf("failed to decode record: %w", err)
+	}
+	if len(r.Value) == 0 {
+		r = Record{Value: json.RawMessage(line)}
+	}
+	return r, nil
+}
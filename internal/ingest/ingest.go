@@ -0,0 +1,196 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"event-pipeline/internal/config"
+	"event-pipeline/internal/logger"
+	"event-pipeline/internal/metrics"
+	"event-pipeline/internal/models"
+	"event-pipeline/internal/producer"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+// publishTimeout bounds how long a request waits for its delivery report
+const publishTimeout = 10 * time.Second
+
+// Publisher publishes the events received by the server
+type Publisher interface {
+	PublishAsync(ctx context.Context, event models.Publishable) *producer.Future
+}
+
+// Accepted is the response to a published event
+type Accepted struct {
+	EventID   string           `json:"eventId"`
+	EventType models.EventType `json:"eventType"`
+	Partition int32            `json:"partition"`
+	Offset    int64            `json:"offset"`
+}
+
+// errorResponse is the body of a rejected request
+type errorResponse struct {
+	Error   string              `json:"error"`
+	EventID string              `json:"eventId,omitempty"`
+	Fields  []models.FieldError `json:"fields,omitempty"`
+}
+
+// Server accepts events over HTTP and publishes them
+type Server struct {
+	router    *mux.Router
+	cfg       *config.IngestConfig
+	server    *http.Server
+	publisher Publisher
+}
+
+// New creates a new ingestion server
+func New(cfg *config.IngestConfig, publisher Publisher) *Server {
+	s := &Server{
+		router:    mux.NewRouter(),
+		cfg:       cfg,
+		publisher: publisher,
+	}
+
+	s.setupRoutes()
+	return s
+}
+
+// setupRoutes configures the server routes
+func (s *Server) setupRoutes() {
+	s.router.HandleFunc("/health", s.healthCheck).Methods("GET")
+	s.router.HandleFunc("/events", s.publishEvent).Methods("POST")
+	s.router.Handle("/metrics", promhttp.Handler())
+}
+
+// Handler returns the server's routes, e.g. to test them
+func (s *Server) Handler() http.Handler {
+	return s.router
+}
+
+// Start starts the ingestion server
+func (s *Server) Start() error {
+	s.server = &http.Server{
+		Addr:         ":" + s.cfg.Port,
+		Handler:      s.router,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: publishTimeout + 5*time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+
+	logger.Log.Infof("Starting ingestion server on port %s", s.cfg.Port)
+	return s.server.ListenAndServe()
+}
+
+// Stop gracefully stops the ingestion server
+func (s *Server) Stop(ctx context.Context) error {
+	logger.Log.Info("Shutting down ingestion server...")
+	return s.server.Shutdown(ctx)
+}
+
+// healthCheck handles health check requests
+func (s *Server) healthCheck(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status": "healthy",
+		"time":   time.Now().Format(time.RFC3339),
+	})
+}
+
+// publishEvent handles POST /events. The body is a single event whose
+// eventType selects its schema. A missing eventId or timestamp is generated,
+// and the event is validated before it is published. The response is sent
+// once the event is delivered.
+func (s *Server) publishEvent(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.cfg.MaxBodyBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeJSON(w, http.StatusRequestEntityTooLarge, errorResponse{
+				Error: fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit),
+			})
+			return
+		}
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "failed to read request body"})
+		return
+	}
+
+	var base models.BaseEvent
+	if err := json.Unmarshal(body, &base); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid JSON: %v", err)})
+		return
+	}
+
+	event := models.NewEvent(base.EventType)
+	if event == nil {
+		metrics.EventsIngested.WithLabelValues("unknown", "invalid").Inc()
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("unknown eventType %q", base.EventType)})
+		return
+	}
+	if err := json.Unmarshal(body, event); err != nil {
+		metrics.EventsIngested.WithLabelValues(string(base.EventType), "invalid").Inc()
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid %s event: %v", base.EventType, err)})
+		return
+	}
+
+	models.Stamp(event)
+	eventID, eventType := event.GetEventID(), event.GetEventType()
+
+	if v, ok := event.(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			metrics.EventsIngested.WithLabelValues(string(eventType), "invalid").Inc()
+			resp := errorResponse{Error: err.Error(), EventID: eventID}
+			var verr *models.ValidationError
+			if errors.As(err, &verr) {
+				resp.Fields = verr.Fields
+			}
+			writeJSON(w, http.StatusBadRequest, resp)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), publishTimeout)
+	defer cancel()
+
+	f := s.publisher.PublishAsync(ctx, event)
+	select {
+	case <-f.Done():
+	case <-ctx.Done():
+		// The event may still be delivered; clients retrying with the same
+		// eventId are deduplicated by the consumer
+		metrics.EventsIngested.WithLabelValues(string(eventType), "failed").Inc()
+		writeJSON(w, http.StatusGatewayTimeout, errorResponse{Error: "timed out waiting for delivery", EventID: eventID})
+		return
+	}
+
+	result := f.Wait()
+	if result.Err != nil {
+		metrics.EventsIngested.WithLabelValues(string(eventType), "failed").Inc()
+		logger.WithEventID(eventID).WithFields(logrus.Fields{
+			"eventType": eventType,
+			"error":     result.Err.Error(),
+		}).Error("Failed to publish ingested event")
+		writeJSON(w, http.StatusServiceUnavailable, errorResponse{Error: "failed to publish event", EventID: eventID})
+		return
+	}
+
+	metrics.EventsIngested.WithLabelValues(string(eventType), "accepted").Inc()
+	writeJSON(w, http.StatusAccepted, Accepted{
+		EventID:   eventID,
+		EventType: eventType,
+		Partition: result.Partition,
+		Offset:    result.Offset,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,63 @@
+package ingest_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"event-pipeline/internal/config"
+	"event-pipeline/internal/ingest"
+	"event-pipeline/internal/producer"
+)
+
+func TestPublishEvent(t *testing.T) {
+	prod, err := producer.NewFile(&config.FileConfig{Dir: t.TempDir()}, "events")
+	if err != nil {
+		t.Fatalf("Failed to create producer: %v", err)
+	}
+	defer prod.Close()
+
+	handler := ingest.New(&config.IngestConfig{MaxBodyBytes: 1024}, prod).Handler()
+	post := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(body)))
+		return rec
+	}
+
+	rec := post(`{"eventType":"UserCreated","userId":"user-1","email":"user-1@example.com"}`)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d: %s", rec.Code, rec.Body)
+	}
+	var accepted ingest.Accepted
+	if err := json.NewDecoder(rec.Body).Decode(&accepted); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if accepted.EventID == "" || accepted.Offset != 0 {
+		t.Errorf("Expected a generated eventId at offset 0, got %+v", accepted)
+	}
+
+	rec = post(`{"eventId":"evt-2","eventType":"UserCreated","userId":"user-2","email":"user-2@example.com"}`)
+	if err := json.NewDecoder(rec.Body).Decode(&accepted); err != nil || accepted.EventID != "evt-2" || accepted.Offset != 1 {
+		t.Errorf("Expected evt-2 at offset 1, got %+v", accepted)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		code int
+		want string
+	}{
+		{"malformed", `{"eventType":`, http.StatusBadRequest, "invalid JSON"},
+		{"unknown type", `{"eventType":"UserDeleted"}`, http.StatusBadRequest, "unknown eventType"},
+		{"invalid", `{"eventType":"InventoryAdjusted","sku":"SKU-1","quantity":1,"adjustmentType":"set"}`, http.StatusBadRequest, `"field":"adjustmentType"`},
+		{"too large", `{"eventType":"UserCreated","userId":"` + strings.Repeat("x", 1024) + `"}`, http.StatusRequestEntityTooLarge, "exceeds"},
+	}
+	for _, tt := range tests {
+		rec := post(tt.body)
+		if rec.Code != tt.code || !strings.Contains(rec.Body.String(), tt.want) {
+			t.Errorf("%s: expected %d with %q, got %d: %s", tt.name, tt.code, tt.want, rec.Code, rec.Body)
+		}
+	}
+}
@@ -32,6 +32,86 @@ var (
 		},
 	)
 
+	// ConsumerRetries tracks handler retries performed before success or dead-lettering
+	ConsumerRetries = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "consumer_retries_total",
+			Help: "Total number of event handler retries",
+		},
+		[]string{"event_type"},
+	)
+
+	// PartitionPauses tracks partitions paused after transient failures
+	PartitionPauses = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "consumer_partition_pauses_total",
+			Help: "Total number of partition pauses caused by transient failures",
+		},
+		[]string{"event_type"},
+	)
+
+	// BatchSize tracks the number of events written per database batch
+	BatchSize = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "consumer_batch_size",
+			Help:    "Number of events written per database batch",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 10),
+		},
+	)
+
+	// DuplicateEventsSkipped tracks events ignored because they were already processed
+	DuplicateEventsSkipped = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "duplicate_events_skipped_total",
+			Help: "Total number of redelivered events skipped by the processed events ledger",
+		},
+		[]string{"event_type"},
+	)
+
+	// StaleEventsSkipped tracks events older than the projection they would update
+	StaleEventsSkipped = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "stale_events_skipped_total",
+			Help: "Total number of events not applied because the projection holds a newer version",
+		},
+		[]string{"event_type"},
+	)
+
+	// PartitionRebalances tracks partitions assigned, revoked or lost in rebalances
+	PartitionRebalances = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "consumer_partition_rebalances_total",
+			Help: "Total number of partitions assigned, revoked or lost during rebalances",
+		},
+		[]string{"event"},
+	)
+
+	// AssignedPartitions tracks the number of partitions owned by the consumer
+	AssignedPartitions = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "consumer_assigned_partitions",
+			Help: "Number of partitions currently assigned to the consumer",
+		},
+	)
+
+	// RetryTopicForwards tracks messages forwarded to retry topics
+	RetryTopicForwards = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "retry_topic_forwards_total",
+			Help: "Total number of failed events forwarded to a retry topic",
+		},
+		[]string{"event_type", "tier"},
+	)
+
+	// ParkedEvents tracks events parked for a missing parent and what became of them
+	ParkedEvents = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "parked_events_total",
+			Help: "Total number of events parked for a missing parent, replayed or expired",
+		},
+		[]string{"event_type", "outcome"},
+	)
+
 	// DBLatency tracks database operation latency
 	DBLatency = promauto.NewHistogramVec(
 		prometheus.HistogramOpts{
@@ -59,4 +139,99 @@ var (
 			Buckets: prometheus.DefBuckets,
 		},
 	)
+
+	// HandlerDuration tracks the time spent in event handlers
+	HandlerDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "consumer_handler_duration_seconds",
+			Help:    "Event handler latency in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"event_type", "status"},
+	)
+
+	// HandlerPanics tracks handler panics recovered by the consumer
+	HandlerPanics = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "consumer_handler_panics_total",
+			Help: "Total number of event handler panics recovered",
+		},
+		[]string{"event_type"},
+	)
+
+	// ConsumerConnected reports whether each consumer's source is usable (1)
+	// or being reconnected after a fatal error (0)
+	ConsumerConnected = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "consumer_connected",
+			Help: "Whether the consumer's connection is usable (1) or being reconnected (0)",
+		},
+		[]string{"topic"},
+	)
+
+	// ConsumerReconnects tracks connections replaced after a fatal error
+	ConsumerReconnects = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "consumer_reconnects_total",
+			Help: "Total number of consumer connections replaced after a fatal error",
+		},
+		[]string{"topic"},
+	)
+
+	// PollLoopPanics tracks panics recovered in the consumer poll loop
+	PollLoopPanics = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "consumer_poll_panics_total",
+			Help: "Total number of panics recovered in the consumer poll loop",
+		},
+		[]string{"topic"},
+	)
+
+	// DedupChecks tracks duplicate filter lookups by result: hit, miss,
+	// in_progress or error
+	DedupChecks = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "consumer_dedup_checks_total",
+			Help: "Total number of duplicate filter lookups by result",
+		},
+		[]string{"event_type", "result"},
+	)
+
+	// ProducerPending tracks messages published and awaiting a delivery report
+	ProducerPending = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "producer_pending_messages",
+			Help: "Number of published messages awaiting a delivery report",
+		},
+	)
+
+	// OutboxRelayed tracks outbox messages relayed by result: sent, failed
+	// or dead (parked after too many failures)
+	OutboxRelayed = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "outbox_messages_relayed_total",
+			Help: "Total number of outbox messages relayed by result",
+		},
+		[]string{"event_type", "result"},
+	)
+
+	// OutboxLag tracks the time from writing a message to the outbox until
+	// it is published
+	OutboxLag = promauto.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "outbox_lag_seconds",
+			Help:    "Time from writing a message to the outbox until it is published",
+			Buckets: prometheus.DefBuckets,
+		},
+	)
+
+	// EventsIngested tracks events received over HTTP by result: accepted,
+	// invalid or failed
+	EventsIngested = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ingest_events_total",
+			Help: "Total number of events received over HTTP by result",
+		},
+		[]string{"event_type", "result"},
+	)
 )
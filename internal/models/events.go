@@ -3,6 +3,8 @@ package models
 import (
 	"encoding/json"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 // EventType represents the type of event
@@ -15,11 +17,87 @@ const (
 	InventoryAdjustedEvent EventType = "InventoryAdjusted"
 )
 
+// Known reports whether t is one of the event types defined above
+func (t EventType) Known() bool {
+	switch t {
+	case UserCreatedEvent, OrderPlacedEvent, PaymentSettledEvent, InventoryAdjustedEvent:
+		return true
+	}
+	return false
+}
+
 // BaseEvent contains common fields for all events
 type BaseEvent struct {
 	EventID   string    `json:"eventId"`
 	EventType EventType `json:"eventType"`
 	Timestamp time.Time `json:"timestamp"`
+
+	// Sequence optionally orders events for the same entity more precisely
+	// than Timestamp; when set it takes precedence
+	Sequence int64 `json:"sequence,omitempty"`
+}
+
+// Version returns the ordering version of the event: its sequence number
+// when set, otherwise its timestamp in nanoseconds. An event with neither
+// has version 0 and is superseded by any other. Producers should use one
+// scheme consistently for a given entity.
+func (e BaseEvent) Version() int64 {
+	if e.Sequence > 0 {
+		return e.Sequence
+	}
+	if e.Timestamp.IsZero() {
+		return 0
+	}
+	return e.Timestamp.UnixNano()
+}
+
+// GetEventID returns the event's ID
+func (e BaseEvent) GetEventID() string {
+	return e.EventID
+}
+
+// Base returns the event's common fields so they can be filled in
+func (e *BaseEvent) Base() *BaseEvent {
+	return e
+}
+
+// Publishable is implemented by pointers to every event type, e.g.
+// *UserCreated. New event types embed BaseEvent and add GetEventType and
+// GetKey to become publishable.
+type Publishable interface {
+	GetEventType() EventType
+	GetEventID() string
+	GetKey() string
+	Base() *BaseEvent
+}
+
+// Stamp prepares an event to be stored or published: its eventType is set
+// from its Go type, and a missing eventId or timestamp is generated
+func Stamp(event Publishable) {
+	base := event.Base()
+	base.EventType = event.GetEventType()
+	if base.EventID == "" {
+		base.EventID = uuid.New().String()
+	}
+	if base.Timestamp.IsZero() {
+		base.Timestamp = time.Now().UTC()
+	}
+}
+
+// NewEvent returns an empty event of the given type to decode into, or nil
+// if the type is unknown
+func NewEvent(eventType EventType) Publishable {
+	switch eventType {
+	case UserCreatedEvent:
+		return &UserCreated{}
+	case OrderPlacedEvent:
+		return &OrderPlaced{}
+	case PaymentSettledEvent:
+		return &PaymentSettled{}
+	case InventoryAdjustedEvent:
+		return &InventoryAdjusted{}
+	}
+	return nil
 }
 
 // UserCreated event
@@ -32,6 +110,11 @@ type UserCreated struct {
 	CreatedAt time.Time `json:"createdAt"`
 }
 
+// GetEventType returns the type of the event
+func (e UserCreated) GetEventType() EventType {
+	return UserCreatedEvent
+}
+
 // GetKey returns the partition key for the event
 func (e UserCreated) GetKey() string {
 	return e.UserID
@@ -48,6 +131,11 @@ type OrderPlaced struct {
 	PlacedAt    time.Time `json:"placedAt"`
 }
 
+// GetEventType returns the type of the event
+func (e OrderPlaced) GetEventType() EventType {
+	return OrderPlacedEvent
+}
+
 // GetKey returns the partition key for the event
 func (e OrderPlaced) GetKey() string {
 	return e.OrderID
@@ -72,6 +160,11 @@ type PaymentSettled struct {
 	SettledAt       time.Time `json:"settledAt"`
 }
 
+// GetEventType returns the type of the event
+func (e PaymentSettled) GetEventType() EventType {
+	return PaymentSettledEvent
+}
+
 // GetKey returns the partition key for the event
 func (e PaymentSettled) GetKey() string {
 	return e.OrderID
@@ -87,6 +180,11 @@ type InventoryAdjusted struct {
 	AdjustedAt     time.Time `json:"adjustedAt"`
 }
 
+// GetEventType returns the type of the event
+func (e InventoryAdjusted) GetEventType() EventType {
+	return InventoryAdjustedEvent
+}
+
 // GetKey returns the partition key for the event
 func (e InventoryAdjusted) GetKey() string {
 	return e.SKU
@@ -105,4 +203,19 @@ type DLQEntry struct {
 	Error        string    `json:"error"`
 	Timestamp    time.Time `json:"timestamp"`
 	RetryCount   int       `json:"retryCount"`
+	// Fields lists the invalid fields of an event that failed validation
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// ParkedEvent represents an event waiting for the row it references. Key and
+// Partition are those of the message it arrived in, so that its replay can
+// be ordered with the other messages of its key.
+type ParkedEvent struct {
+	EventID      string    `json:"eventId"`
+	Parent       string    `json:"parent"`
+	OriginalData string    `json:"originalData"`
+	Key          string    `json:"key,omitempty"`
+	Partition    int32     `json:"partition"`
+	Error        string    `json:"error"`
+	ParkedAt     time.Time `json:"parkedAt"`
 }
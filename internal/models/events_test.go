@@ -133,3 +133,60 @@ func TestInventoryAdjustedEvent(t *testing.T) {
 		t.Errorf("Expected key %s, got %s", sku, event.GetKey())
 	}
 }
+
+func TestBaseEventVersion(t *testing.T) {
+	now := time.Now()
+	older := models.BaseEvent{Timestamp: now.Add(-time.Second)}
+	newer := models.BaseEvent{Timestamp: now}
+
+	// Without a sequence, versions follow timestamps
+	if older.Version() >= newer.Version() {
+		t.Errorf("Expected older event to have a lower version")
+	}
+
+	// An explicit sequence takes precedence over the timestamp
+	sequenced := models.BaseEvent{Timestamp: now, Sequence: 7}
+	if sequenced.Version() != 7 {
+		t.Errorf("Expected version 7, got %d", sequenced.Version())
+	}
+
+	// An event with neither is older than any timestamped event
+	unversioned := models.BaseEvent{}
+	if unversioned.Version() != 0 || unversioned.Version() >= older.Version() {
+		t.Errorf("Expected version 0 below %d, got %d", older.Version(), unversioned.Version())
+	}
+}
+
+func TestEventsArePublishable(t *testing.T) {
+	tests := []struct {
+		event     models.Publishable
+		eventType models.EventType
+		key       string
+	}{
+		{&models.UserCreated{UserID: "user-1"}, models.UserCreatedEvent, "user-1"},
+		{&models.OrderPlaced{OrderID: "order-1"}, models.OrderPlacedEvent, "order-1"},
+		{&models.PaymentSettled{OrderID: "order-1"}, models.PaymentSettledEvent, "order-1"},
+		{&models.InventoryAdjusted{SKU: "SKU-1"}, models.InventoryAdjustedEvent, "SKU-1"},
+	}
+
+	for _, tt := range tests {
+		tt.event.Base().EventID = "evt-1"
+		if tt.event.GetEventType() != tt.eventType || tt.event.GetKey() != tt.key || tt.event.GetEventID() != "evt-1" {
+			t.Errorf("Unexpected type %s, key %s or ID %s for %T",
+				tt.event.GetEventType(), tt.event.GetKey(), tt.event.GetEventID(), tt.event)
+		}
+	}
+}
+
+func TestEventTypeKnown(t *testing.T) {
+	for _, eventType := range []models.EventType{models.UserCreatedEvent, models.OrderPlacedEvent, models.PaymentSettledEvent, models.InventoryAdjustedEvent} {
+		if !eventType.Known() {
+			t.Errorf("Expected %s to be known", eventType)
+		}
+	}
+	for _, eventType := range []models.EventType{"", "UserCreate", "usercreated"} {
+		if eventType.Known() {
+			t.Errorf("Expected %q to be unknown", eventType)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// FieldError describes why a field of an event is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError reports every invalid field of an event
+type ValidationError struct {
+	EventType EventType    `json:"eventType"`
+	Fields    []FieldError `json:"fields"`
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.Field+" "+f.Message)
+	}
+	return fmt.Sprintf("invalid %s event: %s", e.EventType, strings.Join(msgs, "; "))
+}
+
+// Rule checks an event and returns the fields it finds invalid
+type Rule[E any] func(event E) []FieldError
+
+// invalid returns a single field error
+func invalid(field, message string) []FieldError {
+	return []FieldError{{Field: field, Message: message}}
+}
+
+// Required rejects an empty or blank string
+func Required[E any](field string, value func(E) string) Rule[E] {
+	return func(e E) []FieldError {
+		if strings.TrimSpace(value(e)) == "" {
+			return invalid(field, "is required")
+		}
+		return nil
+	}
+}
+
+// Positive rejects a number that is not greater than zero
+func Positive[E any](field string, value func(E) float64) Rule[E] {
+	return func(e E) []FieldError {
+		if v := value(e); !(v > 0) || math.IsInf(v, 0) {
+			return invalid(field, "must be greater than 0")
+		}
+		return nil
+	}
+}
+
+// NonNegative rejects a number below zero
+func NonNegative[E any](field string, value func(E) float64) Rule[E] {
+	return func(e E) []FieldError {
+		if v := value(e); !(v >= 0) || math.IsInf(v, 0) {
+			return invalid(field, "must not be negative")
+		}
+		return nil
+	}
+}
+
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// Currency rejects anything but a 3-letter ISO 4217 code such as "USD"
+func Currency[E any](field string, value func(E) string) Rule[E] {
+	return func(e E) []FieldError {
+		if !currencyPattern.MatchString(value(e)) {
+			return invalid(field, "must be a 3-letter ISO 4217 currency code")
+		}
+		return nil
+	}
+}
+
+var emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+
+// Email rejects a string that is not an email address
+func Email[E any](field string, value func(E) string) Rule[E] {
+	return func(e E) []FieldError {
+		if !emailPattern.MatchString(value(e)) {
+			return invalid(field, "must be a valid email address")
+		}
+		return nil
+	}
+}
+
+// OneOf rejects a string outside the allowed values
+func OneOf[E any](field string, value func(E) string, allowed ...string) Rule[E] {
+	return func(e E) []FieldError {
+		v := value(e)
+		for _, a := range allowed {
+			if v == a {
+				return nil
+			}
+		}
+		return invalid(field, "must be one of "+strings.Join(allowed, ", "))
+	}
+}
+
+// Each applies rules to every element of a list field, reporting fields as
+// "field[i].name"
+func Each[E, I any](field string, items func(E) []I, rules ...Rule[I]) Rule[E] {
+	return func(e E) []FieldError {
+		var errs []FieldError
+		for i, item := range items(e) {
+			for _, rule := range rules {
+				for _, fe := range rule(item) {
+					fe.Field = fmt.Sprintf("%s[%d].%s", field, i, fe.Field)
+					errs = append(errs, fe)
+				}
+			}
+		}
+		return errs
+	}
+}
+
+// check runs rules against an event
+func check[E any](eventType EventType, event E, rules []Rule[E]) error {
+	var errs []FieldError
+	for _, rule := range rules {
+		errs = append(errs, rule(event)...)
+	}
+	if len(errs) > 0 {
+		return &ValidationError{EventType: eventType, Fields: errs}
+	}
+	return nil
+}
+
+// AdjustmentType values accepted on InventoryAdjusted events
+const (
+	AdjustmentAdd      = "add"
+	AdjustmentSubtract = "subtract"
+)
+
+// amountTolerance absorbs floating point error when comparing amounts
+const amountTolerance = 0.005
+
+func eventID[E interface{ GetEventID() string }](e E) string { return e.GetEventID() }
+
+var userCreatedRules = []Rule[UserCreated]{
+	Required("eventId", eventID[UserCreated]),
+	Required("userId", func(e UserCreated) string { return e.UserID }),
+	Email("email", func(e UserCreated) string { return e.Email }),
+}
+
+var orderItemRules = []Rule[OrderItem]{
+	Required("sku", func(i OrderItem) string { return i.SKU }),
+	Positive("quantity", func(i OrderItem) float64 { return float64(i.Quantity) }),
+	NonNegative("price", func(i OrderItem) float64 { return i.Price }),
+}
+
+var orderPlacedRules = []Rule[OrderPlaced]{
+	Required("eventId", eventID[OrderPlaced]),
+	Required("orderId", func(e OrderPlaced) string { return e.OrderID }),
+	Required("userId", func(e OrderPlaced) string { return e.UserID }),
+	Positive("totalAmount", func(e OrderPlaced) float64 { return e.TotalAmount }),
+	Currency("currency", func(e OrderPlaced) string { return e.Currency }),
+	Each("items", func(e OrderPlaced) []OrderItem { return e.Items }, orderItemRules...),
+	itemsMatchTotal,
+}
+
+var paymentSettledRules = []Rule[PaymentSettled]{
+	Required("eventId", eventID[PaymentSettled]),
+	Required("paymentId", func(e PaymentSettled) string { return e.PaymentID }),
+	Required("orderId", func(e PaymentSettled) string { return e.OrderID }),
+	Positive("amount", func(e PaymentSettled) float64 { return e.Amount }),
+	Currency("currency", func(e PaymentSettled) string { return e.Currency }),
+	Required("status", func(e PaymentSettled) string { return e.Status }),
+}
+
+var inventoryAdjustedRules = []Rule[InventoryAdjusted]{
+	Required("eventId", eventID[InventoryAdjusted]),
+	Required("sku", func(e InventoryAdjusted) string { return e.SKU }),
+	// The direction of the adjustment is its type, so the quantity is never negative
+	Positive("quantity", func(e InventoryAdjusted) float64 { return float64(e.Quantity) }),
+	OneOf("adjustmentType", func(e InventoryAdjusted) string { return e.AdjustmentType }, AdjustmentAdd, AdjustmentSubtract),
+}
+
+// itemsMatchTotal rejects an order whose items do not add up to its total.
+// Orders without items are not checked.
+func itemsMatchTotal(e OrderPlaced) []FieldError {
+	if len(e.Items) == 0 {
+		return nil
+	}
+	var sum float64
+	for _, item := range e.Items {
+		sum += item.Price * float64(item.Quantity)
+	}
+	if math.Abs(sum-e.TotalAmount) > amountTolerance {
+		return invalid("totalAmount", fmt.Sprintf("must equal the sum of item prices (%.2f)", sum))
+	}
+	return nil
+}
+
+// Validate checks the event's fields
+func (e UserCreated) Validate() error { return check(UserCreatedEvent, e, userCreatedRules) }
+
+// Validate checks the event's fields and that its items add up to its total
+func (e OrderPlaced) Validate() error { return check(OrderPlacedEvent, e, orderPlacedRules) }
+
+// Validate checks the event's fields
+func (e PaymentSettled) Validate() error { return check(PaymentSettledEvent, e, paymentSettledRules) }
+
+// Validate checks the event's fields
+func (e InventoryAdjusted) Validate() error {
+	return check(InventoryAdjustedEvent, e, inventoryAdjustedRules)
+}
+
+// Validate decodes an event payload of the given type and checks its rules.
+// Events of unknown types are not checked.
+func Validate(eventType EventType, data []byte) error {
+	switch eventType {
+	case UserCreatedEvent:
+		return decodeAndValidate[UserCreated](data)
+	case OrderPlacedEvent:
+		return decodeAndValidate[OrderPlaced](data)
+	case PaymentSettledEvent:
+		return decodeAndValidate[PaymentSettled](data)
+	case InventoryAdjustedEvent:
+		return decodeAndValidate[InventoryAdjusted](data)
+	}
+	return nil
+}
+
+func decodeAndValidate[E interface{ Validate() error }](data []byte) error {
+	var event E
+	if err := json.Unmarshal(data, &event); err != nil {
+		return fmt.Errorf("failed to unmarshal event: %w", err)
+	}
+	return event.Validate()
+}
@@ -0,0 +1,143 @@
+package models_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"event-pipeline/internal/models"
+)
+
+func TestValidateOrderPlaced(t *testing.T) {
+	order := models.OrderPlaced{
+		BaseEvent:   models.BaseEvent{EventID: "evt-1", EventType: models.OrderPlacedEvent},
+		OrderID:     "order-1",
+		UserID:      "user-1",
+		TotalAmount: 25.50,
+		Currency:    "USD",
+		Items: []models.OrderItem{
+			{SKU: "SKU-1", Quantity: 2, Price: 10.25},
+			{SKU: "SKU-2", Quantity: 1, Price: 5},
+		},
+	}
+	if err := order.Validate(); err != nil {
+		t.Fatalf("Expected a valid order, got %v", err)
+	}
+
+	order.Currency = "usd"
+	order.Items[1].SKU = ""
+	order.Items[1].Quantity = 0
+
+	var verr *models.ValidationError
+	if err := order.Validate(); !errors.As(err, &verr) {
+		t.Fatalf("Expected a validation error, got %v", err)
+	}
+
+	fields := make(map[string]bool)
+	for _, f := range verr.Fields {
+		fields[f.Field] = true
+	}
+	for _, want := range []string{"currency", "items[1].sku", "items[1].quantity", "totalAmount"} {
+		if !fields[want] {
+			t.Errorf("Expected %s to be reported, got %+v", want, verr.Fields)
+		}
+	}
+	if len(verr.Fields) != 4 {
+		t.Errorf("Expected 4 field errors, got %+v", verr.Fields)
+	}
+}
+
+func TestValidateByEventType(t *testing.T) {
+	tests := []struct {
+		name    string
+		event   interface{}
+		invalid []string
+	}{
+		{
+			name: "valid user",
+			event: models.UserCreated{
+				BaseEvent: models.BaseEvent{EventID: "evt-1", EventType: models.UserCreatedEvent},
+				UserID:    "user-1",
+				Email:     "a@b.c",
+			},
+		},
+		{
+			name: "user without id or email",
+			event: models.UserCreated{
+				BaseEvent: models.BaseEvent{EventID: "evt-1", EventType: models.UserCreatedEvent},
+				Email:     "not-an-email",
+			},
+			invalid: []string{"userId", "email"},
+		},
+		{
+			name: "payment with negative amount",
+			event: models.PaymentSettled{
+				BaseEvent: models.BaseEvent{EventID: "evt-1", EventType: models.PaymentSettledEvent},
+				PaymentID: "pay-1",
+				OrderID:   "order-1",
+				Amount:    -5,
+				Currency:  "EUR",
+				Status:    "settled",
+			},
+			invalid: []string{"amount"},
+		},
+		{
+			name: "inventory with unknown adjustment",
+			event: models.InventoryAdjusted{
+				BaseEvent:      models.BaseEvent{EventType: models.InventoryAdjustedEvent},
+				SKU:            "SKU-1",
+				Quantity:       3,
+				AdjustmentType: "returned",
+			},
+			invalid: []string{"eventId", "adjustmentType"},
+		},
+		{
+			name: "inventory with negative quantity",
+			event: models.InventoryAdjusted{
+				BaseEvent:      models.BaseEvent{EventID: "evt-1", EventType: models.InventoryAdjustedEvent},
+				SKU:            "SKU-1",
+				Quantity:       -50,
+				AdjustmentType: models.AdjustmentAdd,
+			},
+			invalid: []string{"quantity"},
+		},
+		{
+			name:  "unknown event type",
+			event: models.BaseEvent{EventID: "evt-1", EventType: "Unknown"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.event)
+			if err != nil {
+				t.Fatalf("Failed to marshal event: %v", err)
+			}
+			var base models.BaseEvent
+			if err := json.Unmarshal(data, &base); err != nil {
+				t.Fatalf("Failed to unmarshal event: %v", err)
+			}
+
+			err = models.Validate(base.EventType, data)
+			if len(tt.invalid) == 0 {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+
+			var verr *models.ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("Expected a validation error, got %v", err)
+			}
+			if len(verr.Fields) != len(tt.invalid) {
+				t.Fatalf("Expected fields %v, got %+v", tt.invalid, verr.Fields)
+			}
+			for i, f := range verr.Fields {
+				if f.Field != tt.invalid[i] {
+					t.Errorf("Expected field %s, got %s", tt.invalid[i], f.Field)
+				}
+			}
+		})
+	}
+}
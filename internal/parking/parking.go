@@ -0,0 +1,123 @@
+package parking
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"event-pipeline/internal/config"
+	"event-pipeline/internal/logger"
+	"event-pipeline/internal/models"
+)
+
+// Store holds events waiting for a parent row, keyed by the missing parent.
+// Each parent has a list of parked events, and an index sorted by the time
+// the parent's first event was parked drives expiry.
+type Store struct {
+	client *redis.Client
+	prefix string
+}
+
+// New creates a new parking store
+func New(cfg *config.RedisConfig) (*Store, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.GetRedisAddr(),
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+
+	// Test connection
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	return &Store{
+		client: client,
+		prefix: cfg.ParkingKey,
+	}, nil
+}
+
+// Close closes the Redis connection
+func (s *Store) Close() error {
+	return s.client.Close()
+}
+
+func (s *Store) listKey(parent string) string {
+	return s.prefix + ":" + parent
+}
+
+func (s *Store) indexKey() string {
+	return s.prefix + ":index"
+}
+
+// Park stores an event until its parent is written
+func (s *Store) Park(ctx context.Context, entry models.ParkedEvent) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal parked event: %w", err)
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.RPush(ctx, s.listKey(entry.Parent), data)
+		pipe.ZAddNX(ctx, s.indexKey(), &redis.Z{
+			Score:  float64(entry.ParkedAt.Unix()),
+			Member: entry.Parent,
+		})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to park event: %w", err)
+	}
+
+	logger.WithEventID(entry.EventID).WithField("parent", entry.Parent).Info("Event parked")
+	return nil
+}
+
+// Take removes and returns the events parked under parent, oldest first
+func (s *Store) Take(ctx context.Context, parent string) ([]models.ParkedEvent, error) {
+	var values *redis.StringSliceCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		values = pipe.LRange(ctx, s.listKey(parent), 0, -1)
+		pipe.Del(ctx, s.listKey(parent))
+		pipe.ZRem(ctx, s.indexKey(), parent)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to take parked events: %w", err)
+	}
+
+	entries := make([]models.ParkedEvent, 0, len(values.Val()))
+	for _, value := range values.Val() {
+		var entry models.ParkedEvent
+		if err := json.Unmarshal([]byte(value), &entry); err != nil {
+			logger.Log.Errorf("Failed to unmarshal parked event: %v", err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// Expired returns the parents whose first event was parked before cutoff
+func (s *Store) Expired(ctx context.Context, cutoff time.Time) ([]string, error) {
+	parents, err := s.client.ZRangeByScore(ctx, s.indexKey(), &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(cutoff.Unix(), 10),
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expired parked events: %w", err)
+	}
+	return parents, nil
+}
+
+// GetCount returns the number of parents with parked events
+func (s *Store) GetCount(ctx context.Context) (int64, error) {
+	return s.client.ZCard(ctx, s.indexKey()).Result()
+}
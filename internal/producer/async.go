@@ -0,0 +1,154 @@
+package producer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"event-pipeline/internal/metrics"
+	"event-pipeline/internal/models"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// ErrClosed is the result of a publish still awaiting delivery when the
+// producer was closed
+var ErrClosed = errors.New("producer closed before the message was delivered")
+
+// Result is the outcome of publishing one event
+type Result struct {
+	EventID   string
+	Partition int32
+	Offset    int64
+	Err       error
+}
+
+// Future is the pending result of an asynchronous publish
+type Future struct {
+	eventID string
+	done    chan struct{}
+
+	mu        sync.Mutex
+	result    Result
+	completed bool
+	callbacks []func(Result)
+}
+
+func newFuture(eventID string) *Future {
+	return &Future{eventID: eventID, done: make(chan struct{})}
+}
+
+// failed returns a future that has already failed with err
+func failed(eventID string, err error) *Future {
+	f := newFuture(eventID)
+	f.complete(Result{EventID: eventID, Err: err})
+	return f
+}
+
+// Done is closed once the result is available
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait blocks until the event is delivered or has failed
+func (f *Future) Wait() Result {
+	<-f.done
+	return f.result
+}
+
+// Then calls fn with the result once it is available, straight away if it
+// already is. fn runs on the goroutine reporting deliveries, so it must not
+// block.
+func (f *Future) Then(fn func(Result)) {
+	f.mu.Lock()
+	if !f.completed {
+		f.callbacks = append(f.callbacks, fn)
+		f.mu.Unlock()
+		return
+	}
+	f.mu.Unlock()
+	fn(f.result)
+}
+
+// complete sets the result; only the first call has any effect
+func (f *Future) complete(r Result) {
+	f.mu.Lock()
+	if f.completed {
+		f.mu.Unlock()
+		return
+	}
+	f.result = r
+	f.completed = true
+	callbacks := f.callbacks
+	f.callbacks = nil
+	close(f.done)
+	f.mu.Unlock()
+
+	for _, fn := range callbacks {
+		fn(r)
+	}
+}
+
+// PublishAsync queues an event of any type and returns without waiting for
+// it to be delivered. The event's eventType is set from its Go type. It only
+// blocks while the limit of messages awaiting delivery is reached, giving up
+// when ctx is done. Transports without delivery reports publish before it
+// returns.
+func (p *Producer) PublishAsync(ctx context.Context, event models.Publishable) *Future {
+	return p.publishAsync(ctx, event)
+}
+
+// PublishBatch publishes events without waiting for each delivery in turn,
+// then waits for all of them. Results are in the order of events.
+func (p *Producer) PublishBatch(ctx context.Context, events []models.Publishable) []Result {
+	futures := make([]*Future, len(events))
+	for i, event := range events {
+		futures[i] = p.PublishAsync(ctx, event)
+	}
+
+	results := make([]Result, len(futures))
+	for i, f := range futures {
+		results[i] = f.Wait()
+	}
+	return results
+}
+
+// enqueue hands a message to the sender once a delivery slot is free
+func (p *Producer) enqueue(ctx context.Context, topic string, key, value []byte, headers []kafka.Header, eventID string) *Future {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return failed(eventID, ctx.Err())
+	}
+
+	f := newFuture(eventID)
+	p.mu.Lock()
+	p.pending[f] = struct{}{}
+	p.mu.Unlock()
+	metrics.ProducerPending.Inc()
+
+	start := time.Now()
+	done := func(d delivery, err error) {
+		metrics.KafkaProduceLatency.Observe(time.Since(start).Seconds())
+		metrics.ProducerPending.Dec()
+
+		p.mu.Lock()
+		delete(p.pending, f)
+		p.mu.Unlock()
+		<-p.slots
+
+		f.complete(Result{EventID: eventID, Partition: d.partition, Offset: d.offset, Err: err})
+	}
+
+	if s, ok := p.sender.(asyncSender); ok {
+		if err := s.sendAsync(ctx, topic, key, value, headers, done); err != nil {
+			done(delivery{}, err)
+		}
+		return f
+	}
+
+	d, err := p.sender.send(topic, key, value, headers)
+	done(d, err)
+	return f
+}
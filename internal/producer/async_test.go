@@ -0,0 +1,94 @@
+package producer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"event-pipeline/internal/config"
+	"event-pipeline/internal/models"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// heldSender holds delivery reports until release is called
+type heldSender struct {
+	mu   sync.Mutex
+	held []func(delivery, error)
+}
+
+func (s *heldSender) send(topic string, key, value []byte, headers []kafka.Header) (delivery, error) {
+	return delivery{}, errors.New("not used")
+}
+
+func (s *heldSender) sendAsync(ctx context.Context, topic string, key, value []byte, headers []kafka.Header, done func(delivery, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.held = append(s.held, done)
+	return nil
+}
+
+func (s *heldSender) release() {
+	s.mu.Lock()
+	held := s.held
+	s.held = nil
+	s.mu.Unlock()
+	for i, done := range held {
+		done(delivery{offset: int64(i)}, nil)
+	}
+}
+
+func (s *heldSender) close() {}
+
+func TestPublishAsyncLimitsPendingMessages(t *testing.T) {
+	s := &heldSender{}
+	p := newProducer(s, "events", 1)
+
+	first := p.PublishAsync(context.Background(), &models.UserCreated{UserID: "user-1"})
+
+	var called Result
+	first.Then(func(r Result) { called = r })
+
+	// The only slot is taken until the first delivery is reported
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if r := p.PublishAsync(ctx, &models.UserCreated{UserID: "user-2"}).Wait(); !errors.Is(r.Err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the second publish to wait for a slot, got %+v", r)
+	}
+
+	s.release()
+	if r := first.Wait(); r.Err != nil || called.Err != nil {
+		t.Fatalf("Expected the first publish to succeed, got %+v", r)
+	}
+
+	second := p.PublishAsync(context.Background(), &models.UserCreated{UserID: "user-2"})
+	p.Close()
+	if r := second.Wait(); !errors.Is(r.Err, ErrClosed) {
+		t.Errorf("Expected a publish pending at close to fail, got %+v", r)
+	}
+}
+
+func TestPublishBatchReturnsResultsInOrder(t *testing.T) {
+	p, err := NewFile(&config.FileConfig{Dir: t.TempDir()}, "events")
+	if err != nil {
+		t.Fatalf("Failed to create producer: %v", err)
+	}
+	defer p.Close()
+
+	events := make([]models.Publishable, 3)
+	for i, id := range []string{"evt-1", "evt-2", "evt-3"} {
+		events[i] = &models.UserCreated{BaseEvent: models.BaseEvent{EventID: id}}
+	}
+
+	results := p.PublishBatch(context.Background(), events)
+	for i, r := range results {
+		if r.Err != nil || r.Offset != int64(i) || r.EventID != events[i].GetEventID() {
+			t.Errorf("Unexpected result %d: %+v", i, r)
+		}
+	}
+	if events[0].Base().EventType != models.UserCreatedEvent {
+		t.Errorf("Expected eventType to be set from the Go type, got %q", events[0].Base().EventType)
+	}
+}
@@ -0,0 +1,121 @@
+package producer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"event-pipeline/internal/config"
+	"event-pipeline/internal/filelog"
+	"event-pipeline/internal/logger"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// fileSender appends messages to one NDJSON file per topic
+type fileSender struct {
+	dir string
+
+	mu    sync.Mutex
+	files map[string]*topicFile
+}
+
+// topicFile is an open topic file and the number of lines it holds
+type topicFile struct {
+	file  *os.File
+	lines int64
+}
+
+// NewFile creates a producer that appends events to files in cfg.Dir
+func NewFile(cfg *config.FileConfig, topic string) (*Producer, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create event directory: %w", err)
+	}
+
+	logger.Log.WithField("dir", cfg.Dir).Info("Successfully created file producer")
+
+	return newProducer(&fileSender{dir: cfg.Dir, files: make(map[string]*topicFile)}, topic, 0), nil
+}
+
+// send appends a message as one line; its offset is its line number
+func (s *fileSender) send(topic string, key, value []byte, headers []kafka.Header) (delivery, error) {
+	record := filelog.Record{
+		Key:       string(key),
+		Timestamp: time.Now(),
+		Value:     value,
+	}
+	if len(headers) > 0 {
+		record.Headers = make(map[string]string, len(headers))
+		for _, h := range headers {
+			record.Headers[h.Key] = string(h.Value)
+		}
+	}
+	line, err := filelog.Encode(record)
+	if err != nil {
+		return delivery{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := s.open(topic)
+	if err != nil {
+		return delivery{}, err
+	}
+	if _, err := f.file.Write(line); err != nil {
+		return delivery{}, fmt.Errorf("failed to append to %s: %w", f.file.Name(), err)
+	}
+
+	offset := f.lines
+	f.lines++
+	return delivery{offset: offset}, nil
+}
+
+// open returns the topic's file, opening it for appending on first use
+func (s *fileSender) open(topic string) (*topicFile, error) {
+	if f, ok := s.files[topic]; ok {
+		return f, nil
+	}
+
+	file, err := os.OpenFile(filelog.Path(s.dir, topic), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event file: %w", err)
+	}
+	lines, err := countLines(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read event file: %w", err)
+	}
+
+	f := &topicFile{file: file, lines: lines}
+	s.files[topic] = f
+	return f, nil
+}
+
+// countLines counts the complete lines in r
+func countLines(r io.Reader) (int64, error) {
+	var n int64
+	chunk := make([]byte, 32*1024)
+	for {
+		read, err := r.Read(chunk)
+		n += int64(bytes.Count(chunk[:read], []byte{'\n'}))
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+}
+
+func (s *fileSender) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range s.files {
+		f.file.Sync()
+		f.file.Close()
+	}
+}
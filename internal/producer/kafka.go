@@ -0,0 +1,148 @@
+package producer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"event-pipeline/internal/config"
+	"event-pipeline/internal/logger"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// queueFullBackoff is how long to wait before producing again when the
+// client's local queue is full
+const queueFullBackoff = 10 * time.Millisecond
+
+// kafkaSender produces messages to Kafka. Delivery reports for every message
+// are handled by a single goroutine reading the producer's event channel.
+type kafkaSender struct {
+	producer *kafka.Producer
+	reported chan struct{}
+}
+
+// producerConfig builds the client settings, leaving anything not
+// configured at the client default
+func producerConfig(cfg *config.KafkaConfig) *kafka.ConfigMap {
+	configMap := &kafka.ConfigMap{
+		"bootstrap.servers": cfg.Brokers,
+		"client.id":         "event-producer",
+		"acks":              "all",
+	}
+	if cfg.ProducerIdempotence {
+		configMap.SetKey("enable.idempotence", true)
+	}
+	if cfg.ProducerCompression != "" {
+		configMap.SetKey("compression.codec", cfg.ProducerCompression)
+	}
+	if cfg.ProducerLinger != nil {
+		configMap.SetKey("linger.ms", int(cfg.ProducerLinger.Milliseconds()))
+	}
+	if cfg.ProducerBatchSize != nil {
+		configMap.SetKey("batch.size", *cfg.ProducerBatchSize)
+	}
+	if cfg.ProducerBatchMessages != nil {
+		configMap.SetKey("batch.num.messages", *cfg.ProducerBatchMessages)
+	}
+	if cfg.ProducerMaxInFlight != nil {
+		configMap.SetKey("max.in.flight.requests.per.connection", *cfg.ProducerMaxInFlight)
+	}
+	if cfg.ProducerDeliveryTimeout != nil {
+		configMap.SetKey("delivery.timeout.ms", int(cfg.ProducerDeliveryTimeout.Milliseconds()))
+	}
+	if cfg.ProducerRetries != nil {
+		configMap.SetKey("retries", *cfg.ProducerRetries)
+	}
+	return configMap
+}
+
+func newKafkaSender(cfg *config.KafkaConfig) (*kafkaSender, error) {
+	p, err := kafka.NewProducer(producerConfig(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create producer: %w", err)
+	}
+
+	s := &kafkaSender{producer: p, reported: make(chan struct{})}
+	go s.reportDeliveries()
+	return s, nil
+}
+
+// reportDeliveries passes delivery reports to the callbacks given to
+// sendAsync until the producer is closed
+func (s *kafkaSender) reportDeliveries() {
+	defer close(s.reported)
+
+	for e := range s.producer.Events() {
+		switch ev := e.(type) {
+		case *kafka.Message:
+			done, ok := ev.Opaque.(func(delivery, error))
+			if !ok {
+				continue
+			}
+			if ev.TopicPartition.Error != nil {
+				done(delivery{}, fmt.Errorf("delivery failed: %w", ev.TopicPartition.Error))
+				continue
+			}
+			done(delivery{partition: ev.TopicPartition.Partition, offset: int64(ev.TopicPartition.Offset)}, nil)
+		case kafka.Error:
+			logger.Log.Errorf("Kafka producer error: %v", ev)
+		}
+	}
+}
+
+// sendAsync produces a message; done is called with its delivery report
+func (s *kafkaSender) sendAsync(ctx context.Context, topic string, key, value []byte, headers []kafka.Header, done func(delivery, error)) error {
+	msg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Key:            key,
+		Value:          value,
+		Headers:        headers,
+		Opaque:         done,
+	}
+
+	for {
+		err := s.producer.Produce(msg, nil)
+
+		var kafkaErr kafka.Error
+		if !errors.As(err, &kafkaErr) || kafkaErr.Code() != kafka.ErrQueueFull {
+			if err != nil {
+				return fmt.Errorf("failed to produce message: %w", err)
+			}
+			return nil
+		}
+
+		// Wait for deliveries to make room
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to produce message: %w", ctx.Err())
+		case <-time.After(queueFullBackoff):
+		}
+	}
+}
+
+// send produces a message and waits for its delivery report
+func (s *kafkaSender) send(topic string, key, value []byte, headers []kafka.Header) (delivery, error) {
+	type report struct {
+		d   delivery
+		err error
+	}
+	reports := make(chan report, 1)
+
+	err := s.sendAsync(context.Background(), topic, key, value, headers, func(d delivery, err error) {
+		reports <- report{d, err}
+	})
+	if err != nil {
+		return delivery{}, err
+	}
+
+	r := <-reports
+	return r.d, r.err
+}
+
+func (s *kafkaSender) close() {
+	s.producer.Flush(5000)
+	s.producer.Close()
+	<-s.reported
+}
@@ -0,0 +1,59 @@
+package producer
+
+import (
+	"testing"
+	"time"
+
+	"event-pipeline/internal/config"
+)
+
+func TestProducerConfig(t *testing.T) {
+	cfg := &config.KafkaConfig{
+		Brokers:                 "localhost:9092",
+		ProducerIdempotence:     true,
+		ProducerCompression:     "zstd",
+		ProducerLinger:          ptr(20 * time.Millisecond),
+		ProducerMaxInFlight:     ptr(5),
+		ProducerDeliveryTimeout: ptr(2 * time.Minute),
+	}
+
+	configMap := producerConfig(cfg)
+	want := map[string]interface{}{
+		"acks":                                  "all",
+		"enable.idempotence":                    true,
+		"compression.codec":                     "zstd",
+		"linger.ms":                             20,
+		"max.in.flight.requests.per.connection": 5,
+		"delivery.timeout.ms":                   120000,
+	}
+	for key, value := range want {
+		if got, err := configMap.Get(key, nil); err != nil || got != value {
+			t.Errorf("Expected %s=%v, got %v", key, value, got)
+		}
+	}
+
+	// Unset values are left to the client
+	for _, key := range []string{"batch.size", "batch.num.messages", "retries"} {
+		if _, ok := (*configMap)[key]; ok {
+			t.Errorf("Expected %s to be unset", key)
+		}
+	}
+}
+
+func TestProducerConfigPassesZeros(t *testing.T) {
+	cfg := &config.KafkaConfig{
+		Brokers:                 "localhost:9092",
+		ProducerLinger:          ptr(time.Duration(0)),
+		ProducerDeliveryTimeout: ptr(time.Duration(0)),
+		ProducerRetries:         ptr(0),
+	}
+
+	configMap := producerConfig(cfg)
+	for _, key := range []string{"linger.ms", "delivery.timeout.ms", "retries"} {
+		if got, err := configMap.Get(key, nil); err != nil || got != 0 {
+			t.Errorf("Expected %s=0 to be passed to the client, got %v", key, got)
+		}
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
@@ -1,9 +1,10 @@
 package producer
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"time"
+	"sync"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"github.com/sirupsen/logrus"
@@ -13,109 +14,152 @@ import (
 	"event-pipeline/internal/models"
 )
 
-// Producer wraps Kafka producer
+// Producer publishes events to the configured transport
 type Producer struct {
-	producer *kafka.Producer
-	topic    string
+	sender sender
+	topic  string
+
+	// A slot is held by every message awaiting its delivery report
+	slots chan struct{}
+
+	mu      sync.Mutex
+	pending map[*Future]struct{}
+}
+
+// sender writes encoded messages to a transport
+type sender interface {
+	send(topic string, key, value []byte, headers []kafka.Header) (delivery, error)
+	close()
+}
+
+// asyncSender is a sender that can report deliveries through a callback
+// instead of blocking until each message is written
+type asyncSender interface {
+	sender
+	sendAsync(ctx context.Context, topic string, key, value []byte, headers []kafka.Header, done func(delivery, error)) error
+}
+
+// delivery describes where a message was written
+type delivery struct {
+	partition int32
+	offset    int64
+}
+
+// defaultMaxPending limits the messages awaiting delivery when the
+// configuration does not
+const defaultMaxPending = 10000
+
+// newProducer creates a producer allowing maxPending messages to await
+// delivery at once
+func newProducer(s sender, topic string, maxPending int) *Producer {
+	if maxPending < 1 {
+		maxPending = defaultMaxPending
+	}
+	return &Producer{
+		sender:  s,
+		topic:   topic,
+		slots:   make(chan struct{}, maxPending),
+		pending: make(map[*Future]struct{}),
+	}
 }
 
 // New creates a new Kafka producer
 func New(cfg *config.KafkaConfig) (*Producer, error) {
-	p, err := kafka.NewProducer(&kafka.ConfigMap{
-		"bootstrap.servers": cfg.Brokers,
-		"client.id":         "event-producer",
-		"acks":              "all",
-	})
-
+	s, err := newKafkaSender(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create producer: %w", err)
+		return nil, err
 	}
 
 	logger.Log.Info("Successfully created Kafka producer")
 
-	return &Producer{
-		producer: p,
-		topic:    cfg.Topic,
-	}, nil
+	return newProducer(s, cfg.Topic, cfg.ProducerMaxPending), nil
+}
+
+// NewFromConfig creates a producer for the transport selected in cfg
+func NewFromConfig(cfg *config.Config) (*Producer, error) {
+	switch cfg.Transport {
+	case config.TransportRedis:
+		return NewRedis(&cfg.Redis, cfg.Kafka.Topic)
+	case config.TransportFile:
+		return NewFile(&cfg.File, cfg.Kafka.Topic)
+	default:
+		return New(&cfg.Kafka)
+	}
 }
 
-// Close closes the producer
+// Close flushes pending messages and closes the producer. Publishes still
+// awaiting delivery afterwards fail with ErrClosed.
 func (p *Producer) Close() {
-	p.producer.Flush(5000)
-	p.producer.Close()
+	p.sender.close()
+
+	p.mu.Lock()
+	pending := p.pending
+	p.pending = make(map[*Future]struct{})
+	p.mu.Unlock()
+
+	metrics.ProducerPending.Sub(float64(len(pending)))
+	for f := range pending {
+		f.complete(Result{EventID: f.eventID, Err: ErrClosed})
+	}
+}
+
+// Publish publishes an event of any type and waits for its delivery. The
+// event's eventType is set from its Go type.
+func (p *Producer) Publish(ctx context.Context, event models.Publishable) error {
+	return p.PublishAsync(ctx, event).Wait().Err
 }
 
 // PublishUserCreated publishes a UserCreated event
 func (p *Producer) PublishUserCreated(event models.UserCreated) error {
-	event.EventType = models.UserCreatedEvent
-	return p.publish(event.GetKey(), event)
+	return p.Publish(context.Background(), &event)
 }
 
 // PublishOrderPlaced publishes an OrderPlaced event
 func (p *Producer) PublishOrderPlaced(event models.OrderPlaced) error {
-	event.EventType = models.OrderPlacedEvent
-	return p.publish(event.GetKey(), event)
+	return p.Publish(context.Background(), &event)
 }
 
 // PublishPaymentSettled publishes a PaymentSettled event
 func (p *Producer) PublishPaymentSettled(event models.PaymentSettled) error {
-	event.EventType = models.PaymentSettledEvent
-	return p.publish(event.GetKey(), event)
+	return p.Publish(context.Background(), &event)
 }
 
 // PublishInventoryAdjusted publishes an InventoryAdjusted event
 func (p *Producer) PublishInventoryAdjusted(event models.InventoryAdjusted) error {
-	event.EventType = models.InventoryAdjustedEvent
-	return p.publish(event.GetKey(), event)
+	return p.Publish(context.Background(), &event)
 }
 
-// publish sends an event to Kafka
-func (p *Producer) publish(key string, event interface{}) error {
-	start := time.Now()
-	defer func() {
-		metrics.KafkaProduceLatency.Observe(time.Since(start).Seconds())
-	}()
+// publishAsync encodes an event and queues it for the producer's topic
+func (p *Producer) publishAsync(ctx context.Context, event models.Publishable) *Future {
+	event.Base().EventType = event.GetEventType()
+	eventID, eventType := event.GetEventID(), event.GetEventType()
 
 	data, err := json.Marshal(event)
 	if err != nil {
-		return fmt.Errorf("failed to marshal event: %w", err)
-	}
-
-	// Extract eventID for logging
-	var baseEvent models.BaseEvent
-	if err := json.Unmarshal(data, &baseEvent); err != nil {
-		return fmt.Errorf("failed to extract base event: %w", err)
-	}
-
-	deliveryChan := make(chan kafka.Event)
-	
-	err = p.producer.Produce(&kafka.Message{
-		TopicPartition: kafka.TopicPartition{Topic: &p.topic, Partition: kafka.PartitionAny},
-		Key:            []byte(key),
-		Value:          data,
-	}, deliveryChan)
-
-	if err != nil {
-		return fmt.Errorf("failed to produce message: %w", err)
+		return failed(eventID, fmt.Errorf("failed to marshal event: %w", err))
 	}
 
-	// Wait for delivery report
-	e := <-deliveryChan
-	m := e.(*kafka.Message)
-
-	if m.TopicPartition.Error != nil {
-		logger.WithEventID(baseEvent.EventID).WithFields(logrus.Fields{
-			"eventType": baseEvent.EventType,
-			"error":     m.TopicPartition.Error.Error(),
-		}).Error("Failed to deliver message")
-		return fmt.Errorf("delivery failed: %w", m.TopicPartition.Error)
-	}
-
-	logger.WithEventID(baseEvent.EventID).WithFields(logrus.Fields{
-		"eventType": baseEvent.EventType,
-		"partition": m.TopicPartition.Partition,
-		"offset":    m.TopicPartition.Offset,
-	}).Info("Message delivered successfully")
+	f := p.enqueue(ctx, p.topic, []byte(event.GetKey()), data, nil, eventID)
+	f.Then(func(r Result) {
+		if r.Err != nil {
+			logger.WithEventID(eventID).WithFields(logrus.Fields{
+				"eventType": eventType,
+				"error":     r.Err.Error(),
+			}).Error("Failed to deliver message")
+			return
+		}
+
+		logger.WithEventID(eventID).WithFields(logrus.Fields{
+			"eventType": eventType,
+			"partition": r.Partition,
+			"offset":    r.Offset,
+		}).Info("Message delivered successfully")
+	})
+	return f
+}
 
-	return nil
+// PublishRaw sends an already encoded message to the given topic, e.g. to
+// forward a failed message to a retry topic with its headers
+func (p *Producer) PublishRaw(topic string, key, value []byte, headers []kafka.Header) error {
+	return p.enqueue(context.Background(), topic, key, value, headers, "").Wait().Err
 }
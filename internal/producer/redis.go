@@ -0,0 +1,69 @@
+package producer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"event-pipeline/internal/config"
+	"event-pipeline/internal/logger"
+	"event-pipeline/internal/streams"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/go-redis/redis/v8"
+)
+
+// redisSender appends messages to Redis Streams, one stream per shard of a
+// topic, choosing the shard by message key
+type redisSender struct {
+	client *redis.Client
+	shards int
+	maxLen int64
+}
+
+// NewRedis creates a producer that publishes to Redis Streams
+func NewRedis(cfg *config.RedisConfig, topic string) (*Producer, error) {
+	client, err := streams.Connect(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Log.WithField("shards", cfg.StreamShards).Info("Successfully created Redis Streams producer")
+
+	return newProducer(&redisSender{client: client, shards: cfg.StreamShards, maxLen: cfg.StreamMaxLen}, topic, 0), nil
+}
+
+// send adds a message to the stream of its key's shard
+func (s *redisSender) send(topic string, key, value []byte, headers []kafka.Header) (delivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	values := map[string]interface{}{
+		streams.FieldKey:   key,
+		streams.FieldValue: value,
+	}
+	for _, h := range headers {
+		values[streams.HeaderPrefix+h.Key] = h.Value
+	}
+
+	shard := streams.Shard(key, s.shards)
+	id, err := s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: streams.Key(topic, shard),
+		MaxLen: s.maxLen,
+		Approx: true,
+		Values: values,
+	}).Result()
+	if err != nil {
+		return delivery{}, fmt.Errorf("failed to add to stream: %w", err)
+	}
+
+	offset, err := streams.Offset(id)
+	if err != nil {
+		return delivery{}, err
+	}
+	return delivery{partition: shard, offset: offset}, nil
+}
+
+func (s *redisSender) close() {
+	s.client.Close()
+}
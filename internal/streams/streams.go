@@ -0,0 +1,90 @@
+package streams
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"time"
+
+	"event-pipeline/internal/config"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Fields of a stream entry; headers are stored as HeaderPrefix+name
+const (
+	FieldKey     = "key"
+	FieldValue   = "value"
+	HeaderPrefix = "h:"
+)
+
+// seqBits is the number of offset bits holding the sequence part of an
+// entry ID; the rest hold its millisecond timestamp
+const seqBits = 20
+
+// Connect opens a Redis client for the streams transport
+func Connect(cfg *config.RedisConfig) (*redis.Client, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.GetRedisAddr(),
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+
+	// Test connection
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+	return client, nil
+}
+
+// Key returns the stream holding one shard of a topic, e.g. "events:2"
+func Key(topic string, shard int32) string {
+	return fmt.Sprintf("%s:%d", topic, shard)
+}
+
+// Shard returns the shard for a message key, so that messages with the same
+// key always land in the same stream
+func Shard(key []byte, shards int) int32 {
+	h := fnv.New32a()
+	h.Write(key)
+	return int32(h.Sum32() % uint32(shards))
+}
+
+// Offset converts a stream entry ID ("<ms>-<seq>") into an offset that
+// increases with the ID
+func Offset(id string) (int64, error) {
+	ms, seq, ok := strings.Cut(id, "-")
+	if !ok {
+		return 0, fmt.Errorf("invalid stream ID %q", id)
+	}
+	t, err := strconv.ParseInt(ms, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid stream ID %q: %w", id, err)
+	}
+	n, err := strconv.ParseInt(seq, 10, 64)
+	if err != nil || n >= 1<<seqBits {
+		return 0, fmt.Errorf("invalid stream ID %q: sequence out of range", id)
+	}
+	return t<<seqBits | n, nil
+}
+
+// ID converts an offset back into a stream entry ID
+func ID(offset int64) string {
+	return fmt.Sprintf("%d-%d", offset>>seqBits, offset&(1<<seqBits-1))
+}
+
+// Time returns the time an entry was added, from its offset
+func Time(offset int64) time.Time {
+	return time.UnixMilli(offset >> seqBits)
+}
+
+// TimeOffset returns the lowest offset of entries added at or after t
+func TimeOffset(t time.Time) int64 {
+	return t.UnixMilli() << seqBits
+}
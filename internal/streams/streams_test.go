@@ -0,0 +1,55 @@
+package streams_test
+
+import (
+	"testing"
+	"time"
+
+	"event-pipeline/internal/streams"
+)
+
+func TestOffsetRoundTrip(t *testing.T) {
+	for _, id := range []string{"0-1", "1700000000000-0", "1700000000000-42"} {
+		offset, err := streams.Offset(id)
+		if err != nil {
+			t.Fatalf("Failed to convert %s: %v", id, err)
+		}
+		if got := streams.ID(offset); got != id {
+			t.Errorf("Expected %s, got %s", id, got)
+		}
+	}
+
+	// Offsets follow the ordering of stream IDs
+	a, _ := streams.Offset("1700000000000-99")
+	b, _ := streams.Offset("1700000000001-0")
+	if a >= b {
+		t.Errorf("Expected %d < %d", a, b)
+	}
+	if got := streams.ID(b - 1); got != "1700000000000-1048575" {
+		t.Errorf("Expected the offset before %s to be the last ID of the previous millisecond, got %s", streams.ID(b), got)
+	}
+
+	if _, err := streams.Offset("not-an-id"); err == nil {
+		t.Error("Expected an invalid ID to be rejected")
+	}
+}
+
+func TestTimeOffset(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	offset, _ := streams.Offset("1700000000000-5")
+
+	if streams.TimeOffset(now) > offset {
+		t.Error("Expected entries added at t to be at or after its offset")
+	}
+	if !streams.Time(offset).Equal(now) {
+		t.Errorf("Expected %v, got %v", now, streams.Time(offset))
+	}
+}
+
+func TestShardIsStable(t *testing.T) {
+	if streams.Shard([]byte("order-1"), 4) != streams.Shard([]byte("order-1"), 4) {
+		t.Error("Expected the same key to map to the same shard")
+	}
+	if s := streams.Shard([]byte("order-1"), 1); s != 0 {
+		t.Errorf("Expected shard 0 with a single shard, got %d", s)
+	}
+}
@@ -7,6 +7,8 @@ IF OBJECT_ID('payments', 'U') IS NOT NULL DROP TABLE payments;
 IF OBJECT_ID('orders', 'U') IS NOT NULL DROP TABLE orders;
 IF OBJECT_ID('users', 'U') IS NOT NULL DROP TABLE users;
 IF OBJECT_ID('inventory', 'U') IS NOT NULL DROP TABLE inventory;
+IF OBJECT_ID('processed_events', 'U') IS NOT NULL DROP TABLE processed_events;
+IF OBJECT_ID('outbox', 'U') IS NOT NULL DROP TABLE outbox;
 
 -- Users table
 CREATE TABLE users (
@@ -15,7 +17,8 @@ CREATE TABLE users (
     first_name VARCHAR(100) NOT NULL,
     last_name VARCHAR(100) NOT NULL,
     created_at DATETIME2 NOT NULL DEFAULT GETDATE(),
-    updated_at DATETIME2 NOT NULL DEFAULT GETDATE()
+    updated_at DATETIME2 NOT NULL DEFAULT GETDATE(),
+    version BIGINT NOT NULL DEFAULT 0 -- version of the last applied event
 );
 
 CREATE INDEX idx_users_email ON users(email);
@@ -29,6 +32,7 @@ CREATE TABLE orders (
     currency VARCHAR(3) NOT NULL,
     placed_at DATETIME2 NOT NULL,
     updated_at DATETIME2 NOT NULL DEFAULT GETDATE(),
+    version BIGINT NOT NULL DEFAULT 0, -- version of the last applied event
     FOREIGN KEY (user_id) REFERENCES users(user_id)
 );
 
@@ -58,6 +62,7 @@ CREATE TABLE payments (
     status VARCHAR(20) NOT NULL,
     settled_at DATETIME2 NOT NULL,
     updated_at DATETIME2 NOT NULL DEFAULT GETDATE(),
+    version BIGINT NOT NULL DEFAULT 0, -- version of the last applied event
     FOREIGN KEY (order_id) REFERENCES orders(order_id)
 );
 
@@ -73,5 +78,32 @@ CREATE TABLE inventory (
 
 CREATE INDEX idx_inventory_updated_at ON inventory(updated_at);
 
+-- Processed events ledger (one row per applied event, written in the same
+-- transaction as the projection update so redeliveries are skipped)
+CREATE TABLE processed_events (
+    event_id VARCHAR(100) PRIMARY KEY,
+    event_type VARCHAR(50) NOT NULL,
+    processed_at DATETIME2 NOT NULL DEFAULT GETDATE()
+);
+
+CREATE INDEX idx_processed_events_processed_at ON processed_events(processed_at);
+
+-- Transactional outbox (events written in the same transaction as the
+-- change they describe, published to Kafka by cmd/outbox-relay in id order)
+CREATE TABLE outbox (
+    id BIGINT IDENTITY(1,1) PRIMARY KEY,
+    event_id VARCHAR(100) NOT NULL UNIQUE,
+    event_type VARCHAR(50) NOT NULL,
+    message_key VARCHAR(255) NOT NULL,
+    payload NVARCHAR(MAX) NOT NULL,
+    created_at DATETIME2 NOT NULL DEFAULT GETDATE(),
+    sent_at DATETIME2 NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error NVARCHAR(1000) NULL,
+    dead_at DATETIME2 NULL -- set once attempts reach OUTBOX_MAX_ATTEMPTS
+);
+
+CREATE INDEX idx_outbox_unsent ON outbox(id) INCLUDE (message_key) WHERE sent_at IS NULL AND dead_at IS NULL;
+
 -- Print success message
 PRINT 'Database schema created successfully!';